@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go-subscriptions-workflow/api/webtokens"
+	"go-subscriptions-workflow/services/coupons/service"
+	"go-subscriptions-workflow/types"
+	"net/http"
+)
+
+type couponsHandlers struct {
+	couponsService service.CouponsService
+	inputValidator *validator.Validate
+}
+
+func RegisterCouponsHandlers(couponsService service.CouponsService, app *fiber.App) {
+	h := &couponsHandlers{couponsService: couponsService, inputValidator: validator.New()}
+	app.Post("/admin/coupons", webtokens.RequireAdmin, h.PostCreateCoupon)
+	app.Get("/admin/coupons", webtokens.RequireAdmin, h.GetCoupons)
+	app.Get("/admin/coupons/:id", webtokens.RequireAdmin, h.GetCoupon)
+}
+
+func (h *couponsHandlers) PostCreateCoupon(ctx *fiber.Ctx) error {
+	in := new(types.CreateCouponInput)
+	err := ctx.BodyParser(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	out, err := h.couponsService.CreateCoupon(ctx.Context(), in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnprocessableEntity).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusCreated).
+		JSON(out)
+}
+
+func (h *couponsHandlers) GetCoupons(ctx *fiber.Ctx) error {
+	out, err := h.couponsService.GetCoupons(ctx.Context())
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *couponsHandlers) GetCoupon(ctx *fiber.Ctx) error {
+	out, err := h.couponsService.GetCoupon(ctx.Context(), ctx.Params("id"))
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-subscriptions-workflow/api/webtokens"
+	"go-subscriptions-workflow/services/entitlements/service"
+	"net/http"
+)
+
+type entitlementsHandlers struct {
+	entitlementsService service.EntitlementsService
+}
+
+func RegisterEntitlementsHandlers(entitlementsService service.EntitlementsService, app *fiber.App) {
+	h := &entitlementsHandlers{entitlementsService: entitlementsService}
+	app.Get("/entitlements", h.GetEntitlements)
+}
+
+func (h *entitlementsHandlers) GetEntitlements(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	out, err := h.entitlementsService.GetEntitlements(ctx.Context(), token.UserID)
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go-subscriptions-workflow/api/webtokens"
+	"go-subscriptions-workflow/services/exchangerates/service"
+	"go-subscriptions-workflow/types"
+	"net/http"
+)
+
+type exchangeRatesHandlers struct {
+	exchangeRatesService service.ExchangeRatesService
+	inputValidator       *validator.Validate
+}
+
+func RegisterExchangeRatesHandlers(exchangeRatesService service.ExchangeRatesService, app *fiber.App) {
+	h := &exchangeRatesHandlers{exchangeRatesService: exchangeRatesService, inputValidator: validator.New()}
+	app.Get("/exchange-rates", h.GetExchangeRates)
+	app.Put("/admin/exchange-rates", webtokens.RequireAdmin, h.PutExchangeRate)
+}
+
+func (h *exchangeRatesHandlers) GetExchangeRates(ctx *fiber.Ctx) error {
+	out, err := h.exchangeRatesService.GetRates(ctx.Context())
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *exchangeRatesHandlers) PutExchangeRate(ctx *fiber.Ctx) error {
+	in := new(types.SetExchangeRateInput)
+	err := ctx.BodyParser(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	out, err := h.exchangeRatesService.SetRate(ctx.Context(), in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnprocessableEntity).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go-subscriptions-workflow/api/webtokens"
+	"go-subscriptions-workflow/services/invoices/service"
+	"go-subscriptions-workflow/services/invoices/shared"
+	"go-subscriptions-workflow/types"
+	"net/http"
+)
+
+type invoicesHandlers struct {
+	invoicesService service.InvoicesService
+}
+
+func RegisterInvoicesHandlers(invoicesService service.InvoicesService, app *fiber.App) {
+	h := &invoicesHandlers{invoicesService: invoicesService}
+	app.Get("/invoices", h.GetInvoices)
+	app.Get("/invoices/:id", h.GetInvoice)
+	app.Get("/invoices/:id/credit-notes", h.GetCreditNotes)
+	app.Get("/invoices/:id/receipt", h.GetReceipt)
+}
+
+func (h *invoicesHandlers) GetInvoices(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	out, err := h.invoicesService.GetInvoices(ctx.Context(), token.UserID)
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *invoicesHandlers) GetInvoice(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	in := &types.GetInvoiceInput{ID: ctx.Params("id"), UserID: token.UserID}
+	out, err := h.invoicesService.GetInvoice(ctx.Context(), in)
+	if err == shared.ErrInvoiceNotFound {
+		return ctx.
+			Status(http.StatusNotFound).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *invoicesHandlers) GetCreditNotes(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	in := &types.GetInvoiceInput{ID: ctx.Params("id"), UserID: token.UserID}
+	out, err := h.invoicesService.GetCreditNotes(ctx.Context(), in)
+	if err == shared.ErrInvoiceNotFound {
+		return ctx.
+			Status(http.StatusNotFound).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+// GetReceipt downloads the receipt as PDF, or as HTML with ?format=html.
+func (h *invoicesHandlers) GetReceipt(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	format := ctx.Query("format", "pdf")
+	if format != "pdf" && format != "html" {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": "invalid receipt format"})
+	}
+	in := &types.GetInvoiceInput{ID: ctx.Params("id"), UserID: token.UserID}
+	out, err := h.invoicesService.GetReceipt(ctx.Context(), in)
+	if err == shared.ErrInvoiceNotFound || err == shared.ErrReceiptNotFound {
+		return ctx.
+			Status(http.StatusNotFound).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	ctx.Attachment(fmt.Sprintf("receipt-%d.%s", out.Number, format))
+	if format == "html" {
+		return ctx.
+			Status(http.StatusOK).
+			Send(out.HTML)
+	}
+	return ctx.
+		Status(http.StatusOK).
+		Send(out.PDF)
+}
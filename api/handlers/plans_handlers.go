@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go-subscriptions-workflow/api/webtokens"
+	"go-subscriptions-workflow/services/plans/service"
+	"go-subscriptions-workflow/types"
+	"net/http"
+)
+
+type plansHandlers struct {
+	plansService   service.PlansService
+	inputValidator *validator.Validate
+}
+
+func RegisterPlansHandlers(plansService service.PlansService, app *fiber.App) {
+	h := &plansHandlers{plansService: plansService, inputValidator: validator.New()}
+	app.Get("/plans", h.GetPlans)
+	app.Get("/plans/:id", h.GetPlan)
+	app.Post("/admin/plans", webtokens.RequireAdmin, h.PostCreatePlan)
+	app.Put("/admin/plans/:id", webtokens.RequireAdmin, h.PutUpdatePlan)
+	app.Delete("/admin/plans/:id", webtokens.RequireAdmin, h.DeletePlan)
+}
+
+func (h *plansHandlers) PostCreatePlan(ctx *fiber.Ctx) error {
+	in := new(types.CreatePlanInput)
+	err := ctx.BodyParser(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	out, err := h.plansService.CreatePlan(ctx.Context(), in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnprocessableEntity).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusCreated).
+		JSON(out)
+}
+
+func (h *plansHandlers) PutUpdatePlan(ctx *fiber.Ctx) error {
+	in := new(types.UpdatePlanInput)
+	err := ctx.BodyParser(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	in.ID = ctx.Params("id")
+	out, err := h.plansService.UpdatePlan(ctx.Context(), in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnprocessableEntity).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *plansHandlers) DeletePlan(ctx *fiber.Ctx) error {
+	out, err := h.plansService.ArchivePlan(ctx.Context(), ctx.Params("id"))
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnprocessableEntity).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *plansHandlers) GetPlans(ctx *fiber.Ctx) error {
+	out, err := h.plansService.GetPlans(ctx.Context())
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *plansHandlers) GetPlan(ctx *fiber.Ctx) error {
+	out, err := h.plansService.GetPlan(ctx.Context(), ctx.Params("id"))
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
@@ -1,27 +1,42 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"go-subscriptions-workflow/api/webtokens"
 	"go-subscriptions-workflow/rmq"
 	"go-subscriptions-workflow/services/subscriptions/service"
 	"go-subscriptions-workflow/services/subscriptions/shared"
 	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type subscriptionsHandlers struct {
-	subsClient service.SubscriptionsClient
-	producer   rmq.Producer
+	subsClient     service.SubscriptionsClient
+	producer       rmq.Producer
+	inputValidator *validator.Validate
 }
 
 func RegisterSubscriptionsHandlers(subsClient service.SubscriptionsClient, producer rmq.Producer, app *fiber.App) {
 	h := &subscriptionsHandlers{
-		subsClient: subsClient,
-		producer:   producer,
+		subsClient:     subsClient,
+		producer:       producer,
+		inputValidator: validator.New(),
 	}
 	app.Post("/subscriptions", h.PostStartSubscription)
 	app.Put("/subscriptions/:id/cancel", h.PutCancelSubscription)
+	app.Put("/subscriptions/:id/reactivate", h.PutReactivateSubscription)
+	app.Put("/subscriptions/:id/plan", h.PutChangePlanSubscription)
+	app.Put("/subscriptions/:id/coupon", h.PutApplyCouponSubscription)
+	app.Put("/subscriptions/:id/pause", h.PutPauseSubscription)
+	app.Put("/subscriptions/:id/resume", h.PutResumeSubscription)
+	app.Post("/subscriptions/:id/usage", h.PostRecordUsage)
+	app.Post("/admin/invoices/:id/refund", webtokens.RequireAdmin, h.PostRefund)
+	app.Get("/admin/subscriptions/workflows", webtokens.RequireAdmin, h.GetSubscriptionWorkflows)
 	app.Get("/subscriptions", h.GetSubscriptions)
 	app.Get("/subscriptions/:id", h.GetSubscription)
 }
@@ -33,7 +48,20 @@ func (h *subscriptionsHandlers) PostStartSubscription(ctx *fiber.Ctx) error {
 			Status(http.StatusUnauthorized).
 			JSON(fiber.Map{"error": err.Error()})
 	}
-	req := &types.StartSubscriptionRequest{UserID: token.UserID}
+	req := new(types.StartSubscriptionRequest)
+	err = ctx.BodyParser(req)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(req)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req.UserID = token.UserID
 	options := &rmq.PublisherOptions{
 		ExchangeName: shared.ExchangeName,
 		Persistent:   true,
@@ -56,7 +84,40 @@ func (h *subscriptionsHandlers) PutCancelSubscription(ctx *fiber.Ctx) error {
 			Status(http.StatusUnauthorized).
 			JSON(fiber.Map{"error": err.Error()})
 	}
-	req := &types.CancelSubscriptionRequest{
+	req := new(types.CancelSubscriptionRequest)
+	if len(ctx.Body()) > 0 {
+		err = ctx.BodyParser(req)
+		if err != nil {
+			return ctx.
+				Status(http.StatusBadRequest).
+				JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	req.ID = ctx.Params("id")
+	req.UserID = token.UserID
+	options := &rmq.PublisherOptions{
+		ExchangeName: shared.ExchangeName,
+		Persistent:   true,
+	}
+	err = h.producer.Send(options, rmq.NewMessage(req))
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusAccepted).
+		JSON(fiber.Map{"status": "sent"})
+}
+
+func (h *subscriptionsHandlers) PutReactivateSubscription(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req := &types.ReactivateSubscriptionRequest{
 		ID:     ctx.Params("id"),
 		UserID: token.UserID,
 	}
@@ -75,6 +136,212 @@ func (h *subscriptionsHandlers) PutCancelSubscription(ctx *fiber.Ctx) error {
 		JSON(fiber.Map{"status": "sent"})
 }
 
+func (h *subscriptionsHandlers) PutChangePlanSubscription(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req := new(types.ChangePlanSubscriptionRequest)
+	err = ctx.BodyParser(req)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(req)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req.ID = ctx.Params("id")
+	req.UserID = token.UserID
+	options := &rmq.PublisherOptions{
+		ExchangeName: shared.ExchangeName,
+		Persistent:   true,
+	}
+	err = h.producer.Send(options, rmq.NewMessage(req))
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusAccepted).
+		JSON(fiber.Map{"status": "sent"})
+}
+
+func (h *subscriptionsHandlers) PutApplyCouponSubscription(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req := new(types.ApplyCouponSubscriptionRequest)
+	err = ctx.BodyParser(req)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(req)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req.ID = ctx.Params("id")
+	req.UserID = token.UserID
+	options := &rmq.PublisherOptions{
+		ExchangeName: shared.ExchangeName,
+		Persistent:   true,
+	}
+	err = h.producer.Send(options, rmq.NewMessage(req))
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusAccepted).
+		JSON(fiber.Map{"status": "sent"})
+}
+
+func (h *subscriptionsHandlers) PutPauseSubscription(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req := new(types.PauseSubscriptionRequest)
+	if len(ctx.Body()) > 0 {
+		err = ctx.BodyParser(req)
+		if err != nil {
+			return ctx.
+				Status(http.StatusBadRequest).
+				JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	req.ID = ctx.Params("id")
+	req.UserID = token.UserID
+	options := &rmq.PublisherOptions{
+		ExchangeName: shared.ExchangeName,
+		Persistent:   true,
+	}
+	err = h.producer.Send(options, rmq.NewMessage(req))
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusAccepted).
+		JSON(fiber.Map{"status": "sent"})
+}
+
+func (h *subscriptionsHandlers) PutResumeSubscription(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req := &types.ResumeSubscriptionRequest{
+		ID:     ctx.Params("id"),
+		UserID: token.UserID,
+	}
+	options := &rmq.PublisherOptions{
+		ExchangeName: shared.ExchangeName,
+		Persistent:   true,
+	}
+	err = h.producer.Send(options, rmq.NewMessage(req))
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusAccepted).
+		JSON(fiber.Map{"status": "sent"})
+}
+
+func (h *subscriptionsHandlers) PostRecordUsage(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req := new(types.RecordUsageRequest)
+	err = ctx.BodyParser(req)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(req)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req.ID = ctx.Params("id")
+	req.UserID = token.UserID
+	options := &rmq.PublisherOptions{
+		ExchangeName: shared.ExchangeName,
+		Persistent:   true,
+	}
+	err = h.producer.Send(options, rmq.NewMessage(req))
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusAccepted).
+		JSON(fiber.Map{"status": "sent"})
+}
+
+func (h *subscriptionsHandlers) PostRefund(ctx *fiber.Ctx) error {
+	req := new(types.RefundRequest)
+	if len(ctx.Body()) > 0 {
+		err := ctx.BodyParser(req)
+		if err != nil {
+			return ctx.
+				Status(http.StatusBadRequest).
+				JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	req.InvoiceID = ctx.Params("id")
+	if req.Amount != nil && (!req.Amount.IsPositive() || req.Amount.Validate() != nil) {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": "invalid amount to refund"})
+	}
+	// The refund ID is what makes a redelivered message refund only once, so
+	// it is fixed here and handed back for the caller to retry with.
+	if req.RefundID == "" {
+		req.RefundID = primitive.NewObjectID().Hex()
+	}
+	options := &rmq.PublisherOptions{
+		ExchangeName: shared.ExchangeName,
+		Persistent:   true,
+	}
+	err := h.producer.Send(options, rmq.NewMessage(req))
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusAccepted).
+		JSON(fiber.Map{"status": "sent", "refund_id": req.RefundID})
+}
+
 func (h *subscriptionsHandlers) GetSubscriptions(ctx *fiber.Ctx) error {
 	out, err := h.subsClient.GetSubscriptions(ctx.Context())
 	if err != nil {
@@ -100,3 +367,81 @@ func (h *subscriptionsHandlers) GetSubscription(ctx *fiber.Ctx) error {
 		JSON(out)
 }
 
+// GetSubscriptionWorkflows lists subscription workflows by their search
+// attributes, e.g. ?status=past_due&running=true or
+// ?expires_after=2021-11-01T12:00:00Z&expires_before=2021-11-01T13:00:00Z.
+func (h *subscriptionsHandlers) GetSubscriptionWorkflows(ctx *fiber.Ctx) error {
+	req := &types.SearchSubscriptionWorkflowsRequest{
+		UserID:  ctx.Query("user_id"),
+		Status:  ctx.Query("status"),
+		PlanID:  ctx.Query("plan_id"),
+		Running: ctx.Query("running") == "true",
+	}
+	var err error
+	req.ExpiresAfter, err = queryTime(ctx, "expires_after")
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	req.ExpiresBefore, err = queryTime(ctx, "expires_before")
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if value := ctx.Query("canceled"); value != "" {
+		canceled, err := strconv.ParseBool(value)
+		if err != nil {
+			return ctx.
+				Status(http.StatusBadRequest).
+				JSON(fiber.Map{"error": err.Error()})
+		}
+		req.Canceled = &canceled
+	}
+	if value := ctx.Query("page_size"); value != "" {
+		pageSize, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return ctx.
+				Status(http.StatusBadRequest).
+				JSON(fiber.Map{"error": err.Error()})
+		}
+		req.PageSize = int32(pageSize)
+	}
+	if value := ctx.Query("next_page_token"); value != "" {
+		req.NextPageToken, err = base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return ctx.
+				Status(http.StatusBadRequest).
+				JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	err = h.inputValidator.Struct(req)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	out, err := h.subsClient.SearchWorkflows(ctx.Context(), req)
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func queryTime(ctx *fiber.Ctx, key string) (*time.Time, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go-subscriptions-workflow/api/webtokens"
+	"go-subscriptions-workflow/services/taxes/service"
+	"go-subscriptions-workflow/types"
+	"net/http"
+)
+
+type taxRatesHandlers struct {
+	taxesService   service.TaxesService
+	inputValidator *validator.Validate
+}
+
+func RegisterTaxRatesHandlers(taxesService service.TaxesService, app *fiber.App) {
+	h := &taxRatesHandlers{taxesService: taxesService, inputValidator: validator.New()}
+	app.Get("/tax-rates", h.GetTaxRates)
+	app.Put("/admin/tax-rates", webtokens.RequireAdmin, h.PutTaxRate)
+}
+
+func (h *taxRatesHandlers) GetTaxRates(ctx *fiber.Ctx) error {
+	out, err := h.taxesService.GetRates(ctx.Context())
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *taxRatesHandlers) PutTaxRate(ctx *fiber.Ctx) error {
+	in := new(types.SetTaxRateInput)
+	err := ctx.BodyParser(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	out, err := h.taxesService.SetRate(ctx.Context(), in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnprocessableEntity).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
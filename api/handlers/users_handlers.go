@@ -4,7 +4,11 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"go-subscriptions-workflow/api/webtokens"
+	"go-subscriptions-workflow/rmq"
+	ledgershared "go-subscriptions-workflow/services/ledger/shared"
+	"go-subscriptions-workflow/services/subscriptions/shared"
 	"go-subscriptions-workflow/services/users/service"
+	usersshared "go-subscriptions-workflow/services/users/shared"
 	"go-subscriptions-workflow/types"
 	"go-subscriptions-workflow/util"
 	"net/http"
@@ -12,15 +16,24 @@ import (
 
 type usersHandlers struct {
 	usersService   service.UsersService
+	producer       rmq.Producer
 	inputValidator *validator.Validate
 }
 
-func RegisterUsersHandlers(usersService service.UsersService, app *fiber.App) {
-	h := &usersHandlers{usersService: usersService, inputValidator: validator.New()}
+func RegisterUsersHandlers(usersService service.UsersService, producer rmq.Producer, app *fiber.App) {
+	h := &usersHandlers{usersService: usersService, producer: producer, inputValidator: validator.New()}
 	app.Post("/signup", h.PostSignUp)
 	app.Post("/login", h.PostLogin)
 	app.Get("/users/:id", h.GetUser)
 	app.Put("/users/:id/credit", h.PutCredit)
+	app.Put("/users/:id/currency", h.PutCurrency)
+	app.Put("/users/:id/billing-address", h.PutBillingAddress)
+	app.Get("/users/:id/payment-methods", h.GetPaymentMethods)
+	app.Post("/users/:id/payment-methods", h.PostPaymentMethod)
+	app.Put("/users/:id/payment-methods/:methodId", h.PutPaymentMethod)
+	app.Delete("/users/:id/payment-methods/:methodId", h.DeletePaymentMethod)
+	app.Put("/admin/users/:id/tax-exempt", webtokens.RequireAdmin, h.PutTaxExempt)
+	app.Get("/users/:id/transactions", h.GetTransactions)
 }
 
 func (h *usersHandlers) PostSignUp(ctx *fiber.Ctx) error {
@@ -113,12 +126,273 @@ func (h *usersHandlers) PutCredit(ctx *fiber.Ctx) error {
 			JSON(fiber.Map{"error": err.Error()})
 	}
 	in.UserID = token.UserID
+	in.Reason = ledgershared.ReasonTopUp
+	in.ReferenceID = ""
 	out, err := h.usersService.Credit(ctx.Context(), in)
 	if err != nil {
 		return ctx.
 			Status(http.StatusBadRequest).
 			JSON(fiber.Map{"error": err.Error()})
 	}
+	options := &rmq.PublisherOptions{
+		ExchangeName: shared.ExchangeName,
+		Persistent:   true,
+	}
+	err = h.producer.Send(options, rmq.NewMessage(&types.RetryPaymentRequest{UserID: out.ID}))
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *usersHandlers) PutCurrency(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if token.UserID != ctx.Params("id") {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": "invalid request"})
+	}
+	in := new(types.SetCurrencyInput)
+	err = ctx.BodyParser(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	in.UserID = token.UserID
+	out, err := h.usersService.SetCurrency(ctx.Context(), in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *usersHandlers) PutBillingAddress(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if token.UserID != ctx.Params("id") {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": "invalid request"})
+	}
+	in := new(types.SetBillingAddressInput)
+	err = ctx.BodyParser(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	in.UserID = token.UserID
+	out, err := h.usersService.SetBillingAddress(ctx.Context(), in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *usersHandlers) GetPaymentMethods(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if token.UserID != ctx.Params("id") {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": "invalid request"})
+	}
+	out, err := h.usersService.GetPaymentMethods(ctx.Context(), token.UserID)
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *usersHandlers) PostPaymentMethod(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if token.UserID != ctx.Params("id") {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": "invalid request"})
+	}
+	in := new(types.AddPaymentMethodInput)
+	err = ctx.BodyParser(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	in.UserID = token.UserID
+	out, err := h.usersService.AddPaymentMethod(ctx.Context(), in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusCreated).
+		JSON(out)
+}
+
+func (h *usersHandlers) PutPaymentMethod(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if token.UserID != ctx.Params("id") {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": "invalid request"})
+	}
+	in := new(types.UpdatePaymentMethodInput)
+	err = ctx.BodyParser(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.inputValidator.Struct(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	in.ID = ctx.Params("methodId")
+	in.UserID = token.UserID
+	out, err := h.usersService.UpdatePaymentMethod(ctx.Context(), in)
+	if err == usersshared.ErrPaymentMethodNotFound {
+		return ctx.
+			Status(http.StatusNotFound).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *usersHandlers) DeletePaymentMethod(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if token.UserID != ctx.Params("id") {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": "invalid request"})
+	}
+	in := &types.DeletePaymentMethodInput{ID: ctx.Params("methodId"), UserID: token.UserID}
+	out, err := h.usersService.DeletePaymentMethod(ctx.Context(), in)
+	if err == usersshared.ErrPaymentMethodNotFound {
+		return ctx.
+			Status(http.StatusNotFound).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *usersHandlers) PutTaxExempt(ctx *fiber.Ctx) error {
+	in := new(types.SetTaxExemptInput)
+	err := ctx.BodyParser(in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	in.UserID = ctx.Params("id")
+	out, err := h.usersService.SetTaxExempt(ctx.Context(), in)
+	if err != nil {
+		return ctx.
+			Status(http.StatusBadRequest).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.
+		Status(http.StatusOK).
+		JSON(out)
+}
+
+func (h *usersHandlers) GetTransactions(ctx *fiber.Ctx) error {
+	token, err := webtokens.GetToken(ctx)
+	if err != nil {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": err.Error()})
+	}
+	if token.UserID != ctx.Params("id") {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": "invalid request"})
+	}
+	out, err := h.usersService.GetTransactions(ctx.Context(), token.UserID)
+	if err != nil {
+		return ctx.
+			Status(http.StatusInternalServerError).
+			JSON(fiber.Map{"error": err.Error()})
+	}
 	return ctx.
 		Status(http.StatusOK).
 		JSON(out)
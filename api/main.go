@@ -11,7 +11,15 @@ import (
 	"go-subscriptions-workflow/api/handlers"
 	"go-subscriptions-workflow/db"
 	"go-subscriptions-workflow/rmq"
+	couponssvc "go-subscriptions-workflow/services/coupons/service"
+	entitlementssvc "go-subscriptions-workflow/services/entitlements/service"
+	exchangeratessvc "go-subscriptions-workflow/services/exchangerates/service"
+	invoicessvc "go-subscriptions-workflow/services/invoices/service"
+	ledgersvc "go-subscriptions-workflow/services/ledger/service"
+	paymentsshared "go-subscriptions-workflow/services/payments/shared"
+	planssvc "go-subscriptions-workflow/services/plans/service"
 	subssvc "go-subscriptions-workflow/services/subscriptions/service"
+	taxessvc "go-subscriptions-workflow/services/taxes/service"
 	userssvc "go-subscriptions-workflow/services/users/service"
 	"go-subscriptions-workflow/util"
 	"go.temporal.io/sdk/client"
@@ -26,6 +34,7 @@ func init() {
 	util.PanicOnError(err)
 	db.LoadConfigFromEnv()
 	rmq.LoadConfigFromEnv()
+	paymentsshared.LoadConfigFromEnv()
 	flag.IntVar(&port, "port", 8080, "api port")
 	flag.Parse()
 }
@@ -54,10 +63,25 @@ func main() {
 	app.Use(logger.New())
 	app.Use(cors.New())
 
-	usersService := userssvc.NewUsersService(dbConn)
-	handlers.RegisterUsersHandlers(usersService, app)
+	producer := rmqConn.NewProducer()
+
+	ledgerService := ledgersvc.NewLedgerService(dbConn)
+	usersService := userssvc.NewUsersService(dbConn, ledgerService)
+	handlers.RegisterUsersHandlers(usersService, producer, app)
+	plansService := planssvc.NewPlansService(dbConn)
+	handlers.RegisterPlansHandlers(plansService, app)
+	couponsService := couponssvc.NewCouponsService(dbConn)
+	handlers.RegisterCouponsHandlers(couponsService, app)
+	exchangeRatesService := exchangeratessvc.NewExchangeRatesService(dbConn)
+	handlers.RegisterExchangeRatesHandlers(exchangeRatesService, app)
 	subsClient := subssvc.NewSubscriptionsClient(dbConn, usersService, temporalClient)
-	handlers.RegisterSubscriptionsHandlers(subsClient, rmqConn.NewProducer(), app)
+	handlers.RegisterSubscriptionsHandlers(subsClient, producer, app)
+	entitlementsService := entitlementssvc.NewEntitlementsService(dbConn)
+	handlers.RegisterEntitlementsHandlers(entitlementsService, app)
+	invoicesService := invoicessvc.NewInvoicesService(dbConn)
+	handlers.RegisterInvoicesHandlers(invoicesService, app)
+	taxesService := taxessvc.NewTaxesService(dbConn)
+	handlers.RegisterTaxRatesHandlers(taxesService, app)
 
 	err = app.Listen(fmt.Sprintf(":%d", port))
 	util.PanicOnError(err)
@@ -3,9 +3,20 @@ package webtokens
 import (
 	"github.com/gofiber/fiber/v2"
 	"go-subscriptions-workflow/security/tokens"
+	"net/http"
 )
 
 func GetToken(ctx *fiber.Ctx) (*tokens.TokenPayload, error) {
 	token := ctx.Request().Header.Peek("Authorization")
 	return tokens.Parse(string(token))
 }
+
+func RequireAdmin(ctx *fiber.Ctx) error {
+	key := ctx.Request().Header.Peek("X-Admin-Key")
+	if !tokens.IsAdminKey(string(key)) {
+		return ctx.
+			Status(http.StatusUnauthorized).
+			JSON(fiber.Map{"error": "invalid admin key"})
+	}
+	return ctx.Next()
+}
@@ -0,0 +1,62 @@
+package billing
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	IntervalDaily   = "daily"
+	IntervalWeekly  = "weekly"
+	IntervalMonthly = "monthly"
+	IntervalYearly  = "yearly"
+)
+
+func ValidateInterval(interval string, count int) error {
+	switch interval {
+	case IntervalDaily, IntervalWeekly, IntervalMonthly, IntervalYearly:
+	default:
+		return fmt.Errorf("invalid billing interval: %s", interval)
+	}
+	if count <= 0 {
+		return fmt.Errorf("invalid billing interval count: %d", count)
+	}
+	return nil
+}
+
+// NextPeriodEnd returns the end of the period starting at periodEnd. Monthly and
+// yearly periods land on anchorDay, clamped to the last day of shorter months.
+// An invalid interval is an error rather than a zero-length period.
+func NextPeriodEnd(periodEnd time.Time, interval string, count int, anchorDay int) (time.Time, error) {
+	err := ValidateInterval(interval, count)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch interval {
+	case IntervalDaily:
+		return periodEnd.AddDate(0, 0, count), nil
+	case IntervalWeekly:
+		return periodEnd.AddDate(0, 0, 7*count), nil
+	case IntervalMonthly:
+		return addMonths(periodEnd, count, anchorDay), nil
+	default:
+		return addMonths(periodEnd, 12*count, anchorDay), nil
+	}
+}
+
+func addMonths(t time.Time, months int, anchorDay int) time.Time {
+	if anchorDay <= 0 {
+		anchorDay = t.Day()
+	}
+	year, month, _ := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	day := anchorDay
+	if last := daysIn(first.Year(), first.Month(), t.Location()); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func daysIn(year int, month time.Month, loc *time.Location) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+}
@@ -0,0 +1,67 @@
+package billing_test
+
+import (
+	"go-subscriptions-workflow/billing"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 10, 30, 0, 0, time.UTC)
+}
+
+func TestNextPeriodEnd(t *testing.T) {
+	tests := []struct {
+		name      string
+		periodEnd time.Time
+		interval  string
+		count     int
+		anchorDay int
+		want      time.Time
+	}{
+		{"daily", date(2021, time.January, 31), billing.IntervalDaily, 1, 31, date(2021, time.February, 1)},
+		{"weekly", date(2021, time.December, 28), billing.IntervalWeekly, 2, 28, date(2022, time.January, 11)},
+		{"monthly on anchor", date(2021, time.March, 15), billing.IntervalMonthly, 1, 15, date(2021, time.April, 15)},
+		{"monthly clamped to february", date(2021, time.January, 31), billing.IntervalMonthly, 1, 31, date(2021, time.February, 28)},
+		{"monthly clamped to leap february", date(2024, time.January, 31), billing.IntervalMonthly, 1, 31, date(2024, time.February, 29)},
+		{"monthly back on anchor after clamp", date(2021, time.February, 28), billing.IntervalMonthly, 1, 31, date(2021, time.March, 31)},
+		{"monthly clamped to thirty days", date(2021, time.March, 31), billing.IntervalMonthly, 1, 31, date(2021, time.April, 30)},
+		{"monthly without anchor", date(2021, time.May, 20), billing.IntervalMonthly, 1, 0, date(2021, time.June, 20)},
+		{"quarterly across year", date(2021, time.November, 30), billing.IntervalMonthly, 3, 30, date(2022, time.February, 28)},
+		{"yearly from leap day", date(2024, time.February, 29), billing.IntervalYearly, 1, 29, date(2025, time.February, 28)},
+		{"yearly back on leap day", date(2027, time.February, 28), billing.IntervalYearly, 1, 29, date(2028, time.February, 29)},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := billing.NextPeriodEnd(tt.periodEnd, tt.interval, tt.count, tt.anchorDay)
+			if err != nil {
+				t.Fatalf("next period end: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("next period end = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextPeriodEndInvalidInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval string
+		count    int
+	}{
+		{"empty interval", "", 1},
+		{"unknown interval", "fortnightly", 1},
+		{"zero count", billing.IntervalMonthly, 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := billing.NextPeriodEnd(date(2021, time.January, 31), tt.interval, tt.count, 31)
+			if err == nil {
+				t.Errorf("next period end with interval=%q, count=%d: want error", tt.interval, tt.count)
+			}
+		})
+	}
+}
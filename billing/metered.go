@@ -0,0 +1,95 @@
+package billing
+
+import (
+	"fmt"
+	"go-subscriptions-workflow/money"
+)
+
+const (
+	PricingPerUnit = "per_unit"
+	PricingTiered  = "tiered"
+)
+
+type Tier struct {
+	UpTo      int64
+	UnitPrice money.Money
+	FlatPrice money.Money
+}
+
+func ValidateMeteredPrice(pricing string, unitPrice money.Money, tiers []Tier, currency string) error {
+	switch pricing {
+	case PricingPerUnit:
+		err := validatePrice(unitPrice, currency)
+		if err != nil {
+			return err
+		}
+	case PricingTiered:
+		if len(tiers) == 0 {
+			return fmt.Errorf("tiered pricing requires tiers")
+		}
+		var upTo int64
+		for index := range tiers {
+			last := index == len(tiers)-1
+			err := validatePrice(tiers[index].UnitPrice, currency)
+			if err != nil {
+				return fmt.Errorf("%v: tier=%d", err, index)
+			}
+			err = validatePrice(tiers[index].FlatPrice, currency)
+			if err != nil {
+				return fmt.Errorf("%v: tier=%d", err, index)
+			}
+			if !last && tiers[index].UpTo <= upTo {
+				return fmt.Errorf("tiers must be in ascending order: tier=%d", index)
+			}
+			if last && tiers[index].UpTo != 0 && tiers[index].UpTo <= upTo {
+				return fmt.Errorf("tiers must be in ascending order: tier=%d", index)
+			}
+			upTo = tiers[index].UpTo
+		}
+	default:
+		return fmt.Errorf("invalid metered pricing: %s", pricing)
+	}
+	return nil
+}
+
+// MeteredAmount prices quantity units. Tiered pricing is graduated: each tier
+// bills only the units that fall inside it, and a zero UpTo means no limit.
+func MeteredAmount(pricing string, unitPrice money.Money, tiers []Tier, quantity int64) money.Money {
+	switch pricing {
+	case PricingPerUnit:
+		if quantity <= 0 {
+			return money.Zero(unitPrice.Currency)
+		}
+		return unitPrice.Mul(quantity)
+	case PricingTiered:
+		amount := money.Money{}
+		if len(tiers) > 0 {
+			amount = money.Zero(tiers[0].UnitPrice.Currency)
+		}
+		var billed int64
+		for index := range tiers {
+			if billed >= quantity {
+				break
+			}
+			units := quantity - billed
+			if tiers[index].UpTo > 0 && tiers[index].UpTo-billed < units {
+				units = tiers[index].UpTo - billed
+			}
+			amount.Amount += tiers[index].UnitPrice.Mul(units).Amount + tiers[index].FlatPrice.Amount
+			billed += units
+		}
+		return amount
+	default:
+		return money.Money{}
+	}
+}
+
+func validatePrice(price money.Money, currency string) error {
+	if price.IsNegative() {
+		return fmt.Errorf("invalid price: %v", price)
+	}
+	if price.Currency != currency && !(price.IsZero() && price.Currency == "") {
+		return fmt.Errorf("%w: %s and %s", money.ErrCurrencyMismatch, price.Currency, currency)
+	}
+	return nil
+}
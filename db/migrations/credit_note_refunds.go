@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// creditNoteRefunds makes refund IDs unique across credit notes, so a refund
+// that is retried finds its credit note instead of creating another. Credit
+// notes from before refund IDs have none and are left out of the index.
+func creditNoteRefunds(ctx context.Context, database *mongo.Database) error {
+	index := mongo.IndexModel{
+		Keys: bson.D{{Key: "refund_id", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"refund_id": bson.M{"$gt": ""}}),
+	}
+	_, err := database.Collection("credit_notes").Indexes().CreateOne(ctx, index)
+	return err
+}
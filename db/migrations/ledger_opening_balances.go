@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/money"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"time"
+)
+
+// ledgerOpeningBalances posts the cached balances that existed before the
+// ledger as adjustment transactions, so the ledger sums match them.
+func ledgerOpeningBalances(ctx context.Context, database *mongo.Database) error {
+	cursor, err := database.Collection("users").Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	coll := database.Collection("ledger_entries")
+	posted := 0
+	for cursor.Next(ctx) {
+		var document struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Balances []money.Money      `bson:"balances"`
+		}
+		err = cursor.Decode(&document)
+		if err != nil {
+			return err
+		}
+		for _, balance := range document.Balances {
+			if balance.IsZero() {
+				continue
+			}
+			transactionID := primitive.NewObjectID()
+			createdAt := time.Now()
+			entries := []interface{}{
+				openingEntry(transactionID, fmt.Sprintf("user:%s", document.ID.Hex()), balance, createdAt),
+				openingEntry(transactionID, "system:adjustments", balance.Neg(), createdAt),
+			}
+			_, err = coll.InsertMany(ctx, entries)
+			if err != nil {
+				return err
+			}
+			posted++
+		}
+	}
+	err = cursor.Err()
+	if err != nil {
+		return err
+	}
+	log.Printf("ledger opening balances posted: %d transactions\n", posted)
+	return nil
+}
+
+func openingEntry(transactionID primitive.ObjectID, account string, amount money.Money, createdAt time.Time) bson.M {
+	return bson.M{
+		"_id":            primitive.NewObjectID(),
+		"transaction_id": transactionID,
+		"account":        account,
+		"amount":         amount,
+		"reason":         "adjustment",
+		"reference_id":   "opening_balance",
+		"created_at":     createdAt,
+	}
+}
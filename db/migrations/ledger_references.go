@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ledgerReferences makes charges and refunds unique per account and
+// reference, so a repeated capture or refund fails to post instead of moving
+// money twice. Top-ups and adjustments can share a reference and are left out.
+func ledgerReferences(ctx context.Context, database *mongo.Database) error {
+	keys := bson.D{{Key: "account", Value: 1}, {Key: "reference_id", Value: 1}, {Key: "reason", Value: 1}}
+	indexes := make([]mongo.IndexModel, 0, 2)
+	for _, reason := range []string{"subscription_charge", "refund"} {
+		indexes = append(indexes, mongo.IndexModel{
+			Keys: keys,
+			Options: options.Index().
+				SetName("account_reference_" + reason).
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"reason": reason, "reference_id": bson.M{"$gt": ""}}),
+		})
+	}
+	_, err := database.Collection("ledger_entries").Indexes().CreateMany(ctx, indexes)
+	return err
+}
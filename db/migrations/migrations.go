@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"time"
+)
+
+type Migration struct {
+	ID string
+	Up func(ctx context.Context, database *mongo.Database) error
+}
+
+var migrations = []*Migration{
+	{ID: "0001_money_minor_units", Up: moneyMinorUnits},
+	{ID: "0002_multi_currency_balances", Up: multiCurrencyBalances},
+	{ID: "0003_ledger_opening_balances", Up: ledgerOpeningBalances},
+	{ID: "0004_payment_methods", Up: paymentMethods},
+	{ID: "0005_plan_versions", Up: planVersions},
+	{ID: "0006_trial_plans", Up: trialPlans},
+	{ID: "0007_credit_note_refunds", Up: creditNoteRefunds},
+	{ID: "0008_ledger_references", Up: ledgerReferences},
+}
+
+func Run(ctx context.Context, dbConn db.Connection) error {
+	coll := dbConn.DB().Collection("migrations")
+	for _, migration := range migrations {
+		err := coll.FindOne(ctx, bson.M{"_id": migration.ID}).Err()
+		if err == nil {
+			continue
+		}
+		if err != mongo.ErrNoDocuments {
+			return err
+		}
+		log.Printf("migration running: %s\n", migration.ID)
+		err = migration.Up(ctx, dbConn.DB())
+		if err != nil {
+			return fmt.Errorf("migration failed: id=%v, error=%v", migration.ID, err)
+		}
+		_, err = coll.InsertOne(ctx, bson.M{"_id": migration.ID, "applied_at": time.Now()})
+		if err != nil {
+			return err
+		}
+		log.Printf("migration applied: %s\n", migration.ID)
+	}
+	return nil
+}
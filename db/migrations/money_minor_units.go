@@ -0,0 +1,120 @@
+package migrations
+
+import (
+	"context"
+	"go-subscriptions-workflow/money"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"strings"
+)
+
+var meteredPriceFields = []string{
+	"metered_prices.unit_price",
+	"metered_prices.tiers.unit_price",
+	"metered_prices.tiers.flat_price",
+}
+
+// moneyFields lists, per collection, the dotted paths that held float64
+// amounts. Array fields are walked element by element.
+var moneyFields = map[string][]string{
+	"users":   {"balance"},
+	"plans":   append([]string{"price"}, meteredPriceFields...),
+	"coupons": {"amount_off"},
+	"subscriptions": append([]string{
+		"price",
+		"discount.amount_off",
+		"last_charge.amount",
+		"last_charge.usage",
+		"last_charge.discount",
+	}, meteredPriceFields...),
+}
+
+func moneyMinorUnits(ctx context.Context, database *mongo.Database) error {
+	for collection, fields := range moneyFields {
+		err := convertCollection(ctx, database.Collection(collection), fields)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func convertCollection(ctx context.Context, coll *mongo.Collection, fields []string) error {
+	cursor, err := coll.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	converted := 0
+	for cursor.Next(ctx) {
+		var document bson.M
+		err = cursor.Decode(&document)
+		if err != nil {
+			return err
+		}
+		set := bson.M{}
+		for _, field := range fields {
+			path := strings.Split(field, ".")
+			if convertPath(document, path) {
+				set[path[0]] = document[path[0]]
+			}
+		}
+		if len(set) == 0 {
+			continue
+		}
+		_, err = coll.UpdateByID(ctx, document["_id"], bson.M{"$set": set})
+		if err != nil {
+			return err
+		}
+		converted++
+	}
+	err = cursor.Err()
+	if err != nil {
+		return err
+	}
+	log.Printf("%s converted to money: %d documents\n", coll.Name(), converted)
+	return nil
+}
+
+func convertPath(value interface{}, path []string) bool {
+	switch node := value.(type) {
+	case primitive.M:
+		child, ok := node[path[0]]
+		if !ok || child == nil {
+			return false
+		}
+		if len(path) == 1 {
+			amount, ok := toMoney(child)
+			if ok {
+				node[path[0]] = amount
+			}
+			return ok
+		}
+		return convertPath(child, path[1:])
+	case primitive.A:
+		changed := false
+		for index := range node {
+			if convertPath(node[index], path) {
+				changed = true
+			}
+		}
+		return changed
+	default:
+		return false
+	}
+}
+
+func toMoney(value interface{}) (money.Money, bool) {
+	switch number := value.(type) {
+	case float64:
+		return money.FromFloat(number, money.DefaultCurrency), true
+	case int32:
+		return money.FromFloat(float64(number), money.DefaultCurrency), true
+	case int64:
+		return money.FromFloat(float64(number), money.DefaultCurrency), true
+	default:
+		return money.Money{}, false
+	}
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"context"
+	"go-subscriptions-workflow/money"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+)
+
+func multiCurrencyBalances(ctx context.Context, database *mongo.Database) error {
+	coll := database.Collection("users")
+	cursor, err := coll.Find(ctx, bson.M{"balance": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	converted := 0
+	for cursor.Next(ctx) {
+		var document struct {
+			ID      interface{} `bson:"_id"`
+			Balance money.Money `bson:"balance"`
+		}
+		err = cursor.Decode(&document)
+		if err != nil {
+			return err
+		}
+		balance := document.Balance
+		if balance.Currency == "" {
+			balance.Currency = money.DefaultCurrency
+		}
+		update := bson.M{
+			"$set": bson.M{
+				"currency": balance.Currency,
+				"balances": []money.Money{balance},
+			},
+			"$unset": bson.M{"balance": ""},
+		}
+		_, err = coll.UpdateByID(ctx, document.ID, update)
+		if err != nil {
+			return err
+		}
+		converted++
+	}
+	err = cursor.Err()
+	if err != nil {
+		return err
+	}
+	log.Printf("users converted to multi-currency balances: %d documents\n", converted)
+	return nil
+}
@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"time"
+)
+
+// paymentMethods moves the single payment method a user could set into the
+// list of stored methods, as their first priority.
+func paymentMethods(ctx context.Context, database *mongo.Database) error {
+	coll := database.Collection("users")
+	cursor, err := coll.Find(ctx, bson.M{"payment_method": bson.M{"$ne": nil}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	converted := 0
+	for cursor.Next(ctx) {
+		var document struct {
+			ID            interface{} `bson:"_id"`
+			PaymentMethod struct {
+				Gateway string `bson:"gateway"`
+				Token   string `bson:"token"`
+			} `bson:"payment_method"`
+		}
+		err = cursor.Decode(&document)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		update := bson.M{
+			"$push": bson.M{
+				"payment_methods": bson.M{
+					"_id":        primitive.NewObjectID(),
+					"gateway":    document.PaymentMethod.Gateway,
+					"token":      document.PaymentMethod.Token,
+					"priority":   0,
+					"created_at": now,
+					"updated_at": now,
+				},
+			},
+			"$unset": bson.M{"payment_method": ""},
+		}
+		_, err = coll.UpdateByID(ctx, document.ID, update)
+		if err != nil {
+			return err
+		}
+		converted++
+	}
+	err = cursor.Err()
+	if err != nil {
+		return err
+	}
+	log.Printf("users converted to stored payment methods: %d documents\n", converted)
+	return nil
+}
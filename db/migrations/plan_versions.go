@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+// planVersions snapshots the current version of plans that have none, since
+// subscriptions no longer fall back to the current plan, and makes versions
+// unique per plan.
+func planVersions(ctx context.Context, database *mongo.Database) error {
+	cursor, err := database.Collection("plans").Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	coll := database.Collection("plan_versions")
+	created := 0
+	for cursor.Next(ctx) {
+		var plan bson.M
+		err = cursor.Decode(&plan)
+		if err != nil {
+			return err
+		}
+		filter := bson.M{"plan_id": plan["_id"], "version": plan["version"]}
+		err = coll.FindOne(ctx, filter).Err()
+		if err == nil {
+			continue
+		}
+		if err != mongo.ErrNoDocuments {
+			return err
+		}
+		_, err = coll.InsertOne(ctx, bson.M{
+			"_id":        primitive.NewObjectID(),
+			"plan_id":    plan["_id"],
+			"version":    plan["version"],
+			"plan":       plan,
+			"created_at": time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		created++
+	}
+	err = cursor.Err()
+	if err != nil {
+		return err
+	}
+	log.Printf("plan versions created: %d documents\n", created)
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "plan_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = coll.Indexes().CreateOne(ctx, index)
+	return err
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+)
+
+// trialPlans replaces the single trial recorded on a user with the plans of
+// the subscriptions they started a trial on, since trials are now per plan.
+func trialPlans(ctx context.Context, database *mongo.Database) error {
+	users := database.Collection("users")
+	cursor, err := users.Find(ctx, bson.M{"trial_used_at": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	subscriptions := database.Collection("subscriptions")
+	converted := 0
+	for cursor.Next(ctx) {
+		var document struct {
+			ID interface{} `bson:"_id"`
+		}
+		err = cursor.Decode(&document)
+		if err != nil {
+			return err
+		}
+		filter := bson.M{"user_id": document.ID, "trial_ends_at": bson.M{"$ne": nil}}
+		planIDs, err := subscriptions.Distinct(ctx, "plan_id", filter)
+		if err != nil {
+			return err
+		}
+		update := bson.M{
+			"$addToSet": bson.M{"trial_plan_ids": bson.M{"$each": planIDs}},
+			"$unset":    bson.M{"trial_used_at": ""},
+		}
+		_, err = users.UpdateByID(ctx, document.ID, update)
+		if err != nil {
+			return err
+		}
+		converted++
+	}
+	err = cursor.Err()
+	if err != nil {
+		return err
+	}
+	log.Printf("users converted to trials per plan: %d documents\n", converted)
+	return nil
+}
@@ -0,0 +1,8 @@
+#!/bin/bash
+
+docker exec temporal-admin-tools tctl --auto_confirm admin cluster add-search-attributes \
+ --name UserID --type Keyword \
+ --name Status --type Keyword \
+ --name PlanID --type Keyword \
+ --name ExpiresAt --type Datetime \
+ --name Canceled --type Bool
@@ -5,10 +5,13 @@ go 1.16
 require (
 	github.com/go-playground/validator/v10 v10.9.0
 	github.com/gofiber/fiber/v2 v2.20.1
+	github.com/gogo/protobuf v1.3.2
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/joho/godotenv v1.3.0
 	github.com/streadway/amqp v1.0.0
+	github.com/stretchr/testify v1.7.0
 	go.mongodb.org/mongo-driver v1.7.3
+	go.temporal.io/api v1.5.0
 	go.temporal.io/sdk v1.10.0
 	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97
 )
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/joho/godotenv"
 	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/db/migrations"
 	"go-subscriptions-workflow/util"
 	"log"
 	"time"
@@ -25,4 +26,8 @@ func main() {
 	err := dbConn.Ping(dbCtx)
 	util.PanicOnError(err)
 	log.Println("mongodb connected!")
+
+	err = migrations.Run(context.Background(), dbConn)
+	util.PanicOnError(err)
+	log.Println("migrations applied!")
 }
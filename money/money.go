@@ -0,0 +1,184 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const DefaultCurrency = "USD"
+
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+var exponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"CLP": 0,
+}
+
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount"`
+	Currency string `json:"currency" bson:"currency"`
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+func Zero(currency string) Money {
+	return New(0, currency)
+}
+
+// FromFloat converts a major unit amount, such as 10.5, into minor units. It is
+// meant for migrating legacy values, not for arithmetic.
+func FromFloat(value float64, currency string) Money {
+	return New(int64(math.Round(value*math.Pow10(Exponent(currency)))), currency)
+}
+
+// UnmarshalJSON also accepts the bare major unit number that prices were
+// before they carried a currency, which workflow histories still hold.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && (data[0] == '-' || (data[0] >= '0' && data[0] <= '9')) {
+		var value float64
+		err := json.Unmarshal(data, &value)
+		if err != nil {
+			return err
+		}
+		*m = FromFloat(value, DefaultCurrency)
+		return nil
+	}
+	type money Money
+	return json.Unmarshal(data, (*money)(m))
+}
+
+func Exponent(currency string) int {
+	exponent, ok := exponents[strings.ToUpper(currency)]
+	if !ok {
+		return 2
+	}
+	return exponent
+}
+
+func ValidateCurrency(currency string) error {
+	if len(currency) != 3 || strings.ToUpper(currency) != currency {
+		return fmt.Errorf("invalid currency: %q", currency)
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return fmt.Errorf("invalid currency: %q", currency)
+		}
+	}
+	return nil
+}
+
+func (m Money) Validate() error {
+	return ValidateCurrency(m.Currency)
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.currencyWith(other)
+	if err != nil {
+		return m, err
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(other.Neg())
+}
+
+func (m Money) Cmp(other Money) (int, error) {
+	_, err := m.currencyWith(other)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+func (m Money) Mul(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}
+}
+
+// Ratio scales the amount by ratio, rounding half away from zero to the
+// nearest minor unit.
+func (m Money) Ratio(ratio float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * ratio)), Currency: m.Currency}
+}
+
+func (m Money) Percent(percent float64) Money {
+	return m.Ratio(percent / 100)
+}
+
+func (m Money) String() string {
+	exponent := Exponent(m.Currency)
+	if exponent == 0 {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	unit := int64(math.Pow10(exponent))
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/unit, exponent, amount%unit, m.Currency)
+}
+
+func (m Money) currencyWith(other Money) (string, error) {
+	switch {
+	case m.Currency == other.Currency:
+		return m.Currency, nil
+	case m.Currency == "" && m.Amount == 0:
+		return other.Currency, nil
+	case other.Currency == "" && other.Amount == 0:
+		return m.Currency, nil
+	default:
+		return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+}
+
+func Min(a, b Money) (Money, error) {
+	cmp, err := a.Cmp(b)
+	if err != nil {
+		return a, err
+	}
+	if cmp > 0 {
+		return b, nil
+	}
+	return a, nil
+}
+
+// Convert applies rate, expressed in major units of currency per major unit of
+// m.Currency, and rounds to the nearest minor unit of currency.
+func Convert(m Money, currency string, rate float64) Money {
+	currency = strings.ToUpper(currency)
+	scale := math.Pow10(Exponent(currency) - Exponent(m.Currency))
+	return New(int64(math.Round(float64(m.Amount)*rate*scale)), currency)
+}
@@ -0,0 +1,46 @@
+package money_test
+
+import (
+	"encoding/json"
+	"go-subscriptions-workflow/money"
+	"testing"
+)
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want money.Money
+	}{
+		{"object", `{"amount":1050,"currency":"EUR"}`, money.New(1050, "EUR")},
+		{"legacy integer", `10`, money.New(1000, money.DefaultCurrency)},
+		{"legacy float", `10.5`, money.New(1050, money.DefaultCurrency)},
+		{"legacy negative", `-2.25`, money.New(-225, money.DefaultCurrency)},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var got money.Money
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("unmarshal %s = %v, want %v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONLegacyField(t *testing.T) {
+	var state struct {
+		Price money.Money
+	}
+	err := json.Unmarshal([]byte(`{"Price":10}`), &state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := money.New(1000, money.DefaultCurrency); state.Price != want {
+		t.Errorf("price = %v, want %v", state.Price, want)
+	}
+}
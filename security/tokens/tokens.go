@@ -1,6 +1,7 @@
 package tokens
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"github.com/golang-jwt/jwt"
 	"os"
@@ -31,6 +32,18 @@ func GetSecretKey() []byte {
 	return []byte(os.Getenv("JWT_SECRET_KEY"))
 }
 
+func GetAdminSecretKey() []byte {
+	return []byte(os.Getenv("ADMIN_SECRET_KEY"))
+}
+
+func IsAdminKey(key string) bool {
+	adminKey := GetAdminSecretKey()
+	if len(adminKey) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(adminKey, []byte(key)) == 1
+}
+
 type TokenPayload struct {
 	Issuer    string
 	Audience  string
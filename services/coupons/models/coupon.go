@@ -0,0 +1,45 @@
+package models
+
+import (
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Coupon struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Code           string             `bson:"code"`
+	Type           string             `bson:"type"`
+	PercentOff     float64            `bson:"percent_off"`
+	AmountOff      money.Money        `bson:"amount_off"`
+	Duration       string             `bson:"duration"`
+	DurationCycles int                `bson:"duration_cycles"`
+	MaxRedemptions int                `bson:"max_redemptions"`
+	Redemptions    int                `bson:"redemptions"`
+	RedeemedBy     []string           `bson:"redeemed_by"`
+	ExpiresAt      *time.Time         `bson:"expires_at"`
+	CreatedAt      time.Time          `bson:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at"`
+}
+
+func (c *Coupon) HasExpired(t time.Time) bool {
+	return c.ExpiresAt != nil && c.ExpiresAt.Before(t)
+}
+
+func (c *Coupon) Out() *types.CouponOutput {
+	return &types.CouponOutput{
+		ID:             c.ID.Hex(),
+		Code:           c.Code,
+		Type:           c.Type,
+		PercentOff:     c.PercentOff,
+		AmountOff:      c.AmountOff,
+		Duration:       c.Duration,
+		DurationCycles: c.DurationCycles,
+		MaxRedemptions: c.MaxRedemptions,
+		Redemptions:    c.Redemptions,
+		ExpiresAt:      c.ExpiresAt,
+		CreatedAt:      c.CreatedAt,
+		UpdatedAt:      c.UpdatedAt,
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/services/coupons/models"
+	"go-subscriptions-workflow/services/coupons/shared"
+	"go-subscriptions-workflow/services/coupons/store"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+type CouponsService interface {
+	CreateCoupon(ctx context.Context, in *types.CreateCouponInput) (*types.CouponOutput, error)
+	GetCoupon(ctx context.Context, id string) (*types.CouponOutput, error)
+	GetCouponByCode(ctx context.Context, code string) (*types.CouponOutput, error)
+	GetCoupons(ctx context.Context) ([]*types.CouponOutput, error)
+	RedeemCoupon(ctx context.Context, in *types.CouponRedemptionInput) (*types.CouponOutput, error)
+	ReleaseCoupon(ctx context.Context, in *types.CouponRedemptionInput) (*types.CouponOutput, error)
+}
+
+type couponsService struct {
+	couponsStore store.CouponsStore
+}
+
+func NewCouponsService(dbConn db.Connection) CouponsService {
+	return &couponsService{couponsStore: store.NewCouponsStore(dbConn.DB())}
+}
+
+func (s *couponsService) CreateCoupon(ctx context.Context, in *types.CreateCouponInput) (*types.CouponOutput, error) {
+	code := NormalizeCode(in.Code)
+	if in.Type == shared.TypePercent && (in.PercentOff <= 0 || in.PercentOff > 100) {
+		return nil, fmt.Errorf("invalid percent off: %f", in.PercentOff)
+	}
+	if in.Type == shared.TypeFixed && (!in.AmountOff.IsPositive() || in.AmountOff.Validate() != nil) {
+		return nil, fmt.Errorf("invalid amount off: %v", in.AmountOff)
+	}
+	if in.Duration == shared.DurationRepeating && in.DurationCycles <= 0 {
+		return nil, fmt.Errorf("invalid duration cycles: %d", in.DurationCycles)
+	}
+	_, err := s.couponsStore.GetByCode(ctx, code)
+	if err == nil {
+		return nil, fmt.Errorf("coupon code already registered: %s", code)
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	coupon := &models.Coupon{
+		ID:             primitive.NewObjectID(),
+		Code:           code,
+		Type:           in.Type,
+		Duration:       in.Duration,
+		MaxRedemptions: in.MaxRedemptions,
+		ExpiresAt:      in.ExpiresAt,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	switch in.Type {
+	case shared.TypePercent:
+		coupon.PercentOff = in.PercentOff
+	case shared.TypeFixed:
+		coupon.AmountOff = in.AmountOff
+	}
+	switch in.Duration {
+	case shared.DurationOnce:
+		coupon.DurationCycles = 1
+	case shared.DurationRepeating:
+		coupon.DurationCycles = in.DurationCycles
+	}
+	err = s.couponsStore.Create(ctx, coupon)
+	if err != nil {
+		return nil, err
+	}
+	return coupon.Out(), nil
+}
+
+func (s *couponsService) GetCoupon(ctx context.Context, id string) (*types.CouponOutput, error) {
+	couponID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	coupon, err := s.couponsStore.Get(ctx, couponID)
+	if err != nil {
+		return nil, err
+	}
+	return coupon.Out(), nil
+}
+
+func (s *couponsService) GetCouponByCode(ctx context.Context, code string) (*types.CouponOutput, error) {
+	coupon, err := s.couponsStore.GetByCode(ctx, NormalizeCode(code))
+	if err != nil {
+		return nil, err
+	}
+	return coupon.Out(), nil
+}
+
+func (s *couponsService) GetCoupons(ctx context.Context) ([]*types.CouponOutput, error) {
+	coupons, err := s.couponsStore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.CouponOutput, 0, len(coupons))
+	for index := range coupons {
+		out = append(out, coupons[index].Out())
+	}
+	return out, nil
+}
+
+// RedeemCoupon redeems the coupon for a subscription. Redeeming it again for
+// the same subscription, as a retried activity does, is a no-op.
+func (s *couponsService) RedeemCoupon(ctx context.Context, in *types.CouponRedemptionInput) (*types.CouponOutput, error) {
+	coupon, err := s.couponsStore.GetByCode(ctx, NormalizeCode(in.Code))
+	if err != nil {
+		return nil, err
+	}
+	if coupon.HasExpired(time.Now()) {
+		return nil, shared.ErrCouponExpired
+	}
+	ok, err := s.couponsStore.Redeem(ctx, coupon.ID, in.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, shared.ErrCouponExhausted
+	}
+	coupon, err = s.couponsStore.Get(ctx, coupon.ID)
+	if err != nil {
+		return nil, err
+	}
+	return coupon.Out(), nil
+}
+
+// ReleaseCoupon gives back the redemption of a subscription that could not be
+// started.
+func (s *couponsService) ReleaseCoupon(ctx context.Context, in *types.CouponRedemptionInput) (*types.CouponOutput, error) {
+	coupon, err := s.couponsStore.GetByCode(ctx, NormalizeCode(in.Code))
+	if err != nil {
+		return nil, err
+	}
+	err = s.couponsStore.Release(ctx, coupon.ID, in.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	coupon, err = s.couponsStore.Get(ctx, coupon.ID)
+	if err != nil {
+		return nil, err
+	}
+	return coupon.Out(), nil
+}
+
+func NormalizeCode(code string) string {
+	return strings.TrimSpace(strings.ToUpper(code))
+}
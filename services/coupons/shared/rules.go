@@ -0,0 +1,21 @@
+package shared
+
+import (
+	"errors"
+)
+
+const (
+	TypePercent = "percent"
+	TypeFixed   = "fixed"
+)
+
+const (
+	DurationOnce      = "once"
+	DurationRepeating = "repeating"
+	DurationForever   = "forever"
+)
+
+var (
+	ErrCouponExpired   = errors.New("coupon expired")
+	ErrCouponExhausted = errors.New("coupon max redemptions reached")
+)
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"go-subscriptions-workflow/services/coupons/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"time"
+)
+
+type CouponsStore interface {
+	Create(ctx context.Context, coupon *models.Coupon) error
+	Get(ctx context.Context, id primitive.ObjectID) (*models.Coupon, error)
+	GetByCode(ctx context.Context, code string) (*models.Coupon, error)
+	GetAll(ctx context.Context) ([]*models.Coupon, error)
+	Redeem(ctx context.Context, id primitive.ObjectID, key string) (bool, error)
+	Release(ctx context.Context, id primitive.ObjectID, key string) error
+}
+
+type couponsStore struct {
+	coll *mongo.Collection
+}
+
+func NewCouponsStore(dbConn *mongo.Database) CouponsStore {
+	return &couponsStore{coll: dbConn.Collection("coupons")}
+}
+
+func (s *couponsStore) Create(ctx context.Context, coupon *models.Coupon) error {
+	result, err := s.coll.InsertOne(ctx, coupon)
+	if err != nil {
+		return err
+	}
+	log.Printf("coupon created: %+v\n", result)
+	return nil
+}
+
+func (s *couponsStore) Get(ctx context.Context, id primitive.ObjectID) (*models.Coupon, error) {
+	var coupon models.Coupon
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&coupon)
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (s *couponsStore) GetByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	err := s.coll.FindOne(ctx, bson.M{"code": code}).Decode(&coupon)
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (s *couponsStore) GetAll(ctx context.Context) ([]*models.Coupon, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var coupons []*models.Coupon
+	err = cursor.All(ctx, &coupons)
+	if err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+// Redeem counts a redemption under key unless the coupon is exhausted. A key
+// that already redeemed the coupon succeeds without counting again.
+func (s *couponsStore) Redeem(ctx context.Context, id primitive.ObjectID, key string) (bool, error) {
+
+	filter := bson.M{
+		"_id":         id,
+		"redeemed_by": bson.M{"$ne": key},
+		"$or": bson.A{
+			bson.M{"max_redemptions": 0},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$redemptions", "$max_redemptions"}}},
+		},
+	}
+
+	update := bson.M{
+		"$inc":  bson.M{"redemptions": 1},
+		"$push": bson.M{"redeemed_by": key},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+
+	result, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	log.Printf("coupon redeemed: %+v\n", result)
+	if result.ModifiedCount == 1 {
+		return true, nil
+	}
+	count, err := s.coll.CountDocuments(ctx, bson.M{"_id": id, "redeemed_by": key})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Release takes back the redemption counted under key, if there is one.
+func (s *couponsStore) Release(ctx context.Context, id primitive.ObjectID, key string) error {
+
+	filter := bson.M{"_id": id, "redeemed_by": key}
+
+	update := bson.M{
+		"$inc":  bson.M{"redemptions": -1},
+		"$pull": bson.M{"redeemed_by": key},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+
+	result, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	log.Printf("coupon released: %+v\n", result)
+	return nil
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go-subscriptions-workflow/api/webtokens"
+	"go-subscriptions-workflow/services/entitlements/service"
+	"net/http"
+)
+
+func RequireFeature(entitlementsService service.EntitlementsService, feature string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		token, err := webtokens.GetToken(ctx)
+		if err != nil {
+			return ctx.
+				Status(http.StatusUnauthorized).
+				JSON(fiber.Map{"error": err.Error()})
+		}
+		ok, err := entitlementsService.HasFeature(ctx.Context(), token.UserID, feature)
+		if err != nil {
+			return ctx.
+				Status(http.StatusInternalServerError).
+				JSON(fiber.Map{"error": err.Error()})
+		}
+		if !ok {
+			return ctx.
+				Status(http.StatusForbidden).
+				JSON(fiber.Map{"error": fmt.Sprintf("feature not available: %s", feature)})
+		}
+		return ctx.Next()
+	}
+}
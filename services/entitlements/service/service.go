@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/services/subscriptions/store"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultCacheExpiration bounds how long a cancel, disable or refund made by the
+// subscriptions service can go unseen here, as nothing invalidates the cache.
+const DefaultCacheExpiration = time.Second * 5
+
+type EntitlementsService interface {
+	GetEntitlements(ctx context.Context, userID string) (*types.EntitlementsOutput, error)
+	HasFeature(ctx context.Context, userID string, feature string) (bool, error)
+}
+
+type cacheEntry struct {
+	entitlements *types.EntitlementsOutput
+	expiresAt    time.Time
+}
+
+type entitlementsService struct {
+	subscriptionsStore store.SubscriptionsStore
+	cacheExpiration    time.Duration
+	mu                 sync.Mutex
+	cache              map[string]*cacheEntry
+	prunedAt           time.Time
+}
+
+func NewEntitlementsService(dbConn db.Connection) EntitlementsService {
+	return &entitlementsService{
+		subscriptionsStore: store.NewSubscriptionsStore(dbConn.DB()),
+		cacheExpiration:    DefaultCacheExpiration,
+		cache:              make(map[string]*cacheEntry),
+	}
+}
+
+func (s *entitlementsService) GetEntitlements(ctx context.Context, userID string) (*types.EntitlementsOutput, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.cache[userID]
+	s.mu.Unlock()
+	if ok && entry.expiresAt.After(now) {
+		return entry.entitlements, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, err := s.subscriptionsStore.GetByUserID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	entry = &cacheEntry{
+		entitlements: &types.EntitlementsOutput{UserID: userID, Features: make([]string, 0), CheckedAt: now},
+		expiresAt:    now.Add(s.cacheExpiration),
+	}
+	features := make(map[string]bool)
+	for index := range subscriptions {
+		subscription := subscriptions[index]
+		if !subscription.GrantsAccess(now) {
+			continue
+		}
+		for _, feature := range subscription.Features {
+			features[feature.Name] = true
+		}
+		if accessEndsAt := subscription.AccessEndsAt(); accessEndsAt != nil && accessEndsAt.Before(entry.expiresAt) {
+			entry.expiresAt = *accessEndsAt
+		}
+		if subscription.ExpiresAt.After(now) && subscription.ExpiresAt.Before(entry.expiresAt) {
+			entry.expiresAt = subscription.ExpiresAt
+		}
+	}
+	for name := range features {
+		entry.entitlements.Features = append(entry.entitlements.Features, name)
+	}
+	sort.Strings(entry.entitlements.Features)
+
+	s.mu.Lock()
+	s.prune(now)
+	s.cache[userID] = entry
+	s.mu.Unlock()
+
+	return entry.entitlements, nil
+}
+
+// prune drops expired entries, at most once per expiration, so users that are
+// no longer looked up do not stay in the cache. It is called with mu held.
+func (s *entitlementsService) prune(now time.Time) {
+	if now.Sub(s.prunedAt) < s.cacheExpiration {
+		return
+	}
+	for userID, entry := range s.cache {
+		if !entry.expiresAt.After(now) {
+			delete(s.cache, userID)
+		}
+	}
+	s.prunedAt = now
+}
+
+func (s *entitlementsService) HasFeature(ctx context.Context, userID string, feature string) (bool, error) {
+	entitlements, err := s.GetEntitlements(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for index := range entitlements.Features {
+		if entitlements.Features[index] == feature {
+			return true, nil
+		}
+	}
+	return false, nil
+}
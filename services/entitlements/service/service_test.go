@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"go-subscriptions-workflow/services/subscriptions/models"
+	"go-subscriptions-workflow/services/subscriptions/shared"
+	"go-subscriptions-workflow/services/subscriptions/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// subscriptionsStore serves the subscriptions of one user from memory.
+type subscriptionsStore struct {
+	store.SubscriptionsStore
+	subscriptions []*models.Subscription
+	lookups       int
+}
+
+func (s *subscriptionsStore) GetByUserID(ctx context.Context, userID primitive.ObjectID) ([]*models.Subscription, error) {
+	s.lookups++
+	return s.subscriptions, nil
+}
+
+func newTestService(subscriptions ...*models.Subscription) (*entitlementsService, *subscriptionsStore) {
+	subscriptionsStore := &subscriptionsStore{subscriptions: subscriptions}
+	return &entitlementsService{
+		subscriptionsStore: subscriptionsStore,
+		cacheExpiration:    DefaultCacheExpiration,
+		cache:              make(map[string]*cacheEntry),
+	}, subscriptionsStore
+}
+
+func subscription(status string, expiresAt time.Time, feature string) *models.Subscription {
+	return &models.Subscription{
+		Status:    status,
+		ExpiresAt: expiresAt,
+		Features:  []*models.Feature{{Name: feature}},
+	}
+}
+
+func TestGetEntitlementsGrantsAccess(t *testing.T) {
+	now := time.Now()
+	hour := time.Hour
+	past := now.Add(-hour)
+	future := now.Add(hour)
+
+	canceled := subscription(shared.StatusCanceled, future, "canceled")
+	canceled.Canceled = true
+	canceled.CanceledAt = &past
+	disabled := subscription(shared.StatusDisabled, future, "disabled")
+	disabled.Disabled = true
+	disabled.DisabledAt = &past
+	inGrace := subscription(shared.StatusPastDue, past, "in-grace")
+	inGrace.GraceEndsAt = &future
+	graceOver := subscription(shared.StatusPastDue, past, "grace-over")
+	graceOver.GraceEndsAt = &past
+	noGrace := subscription(shared.StatusPastDue, past, "no-grace")
+
+	tests := []struct {
+		name         string
+		subscription *models.Subscription
+		granted      bool
+	}{
+		{"active", subscription(shared.StatusActive, future, "active"), true},
+		{"active expired", subscription(shared.StatusActive, past, "expired"), false},
+		{"trialing", subscription(shared.StatusTrialing, future, "trialing"), true},
+		{"paused", subscription(shared.StatusPaused, future, "paused"), false},
+		{"canceled", canceled, false},
+		{"disabled", disabled, false},
+		{"past due in grace", inGrace, true},
+		{"past due after grace", graceOver, false},
+		{"past due without grace", noGrace, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(tt.subscription)
+			out, err := s.GetEntitlements(context.Background(), primitive.NewObjectID().Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{}
+			if tt.granted {
+				want = []string{tt.subscription.Features[0].Name}
+			}
+			if !reflect.DeepEqual(out.Features, want) {
+				t.Errorf("features = %v, want %v", out.Features, want)
+			}
+		})
+	}
+}
+
+func TestGetEntitlementsMergesGrantingSubscriptions(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	paused := subscription(shared.StatusPaused, future, "exports")
+	s, _ := newTestService(
+		subscription(shared.StatusActive, future, "reports"),
+		subscription(shared.StatusTrialing, future, "api"),
+		subscription(shared.StatusActive, future, "reports"),
+		paused,
+	)
+	out, err := s.GetEntitlements(context.Background(), primitive.NewObjectID().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"api", "reports"}
+	if !reflect.DeepEqual(out.Features, want) {
+		t.Errorf("features = %v, want %v", out.Features, want)
+	}
+}
+
+func TestGetEntitlementsCachesUntilExpiration(t *testing.T) {
+	s, subscriptionsStore := newTestService(subscription(shared.StatusActive, time.Now().Add(time.Hour), "reports"))
+	userID := primitive.NewObjectID().Hex()
+	for i := 0; i < 2; i++ {
+		_, err := s.GetEntitlements(context.Background(), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if subscriptionsStore.lookups != 1 {
+		t.Errorf("lookups = %d, want 1", subscriptionsStore.lookups)
+	}
+
+	s.cache[userID].expiresAt = time.Now().Add(-time.Second)
+	_, err := s.GetEntitlements(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscriptionsStore.lookups != 2 {
+		t.Errorf("lookups after expiration = %d, want 2", subscriptionsStore.lookups)
+	}
+}
+
+func TestGetEntitlementsPrunesExpiredEntries(t *testing.T) {
+	s, _ := newTestService()
+	now := time.Now()
+	s.cache["expired"] = &cacheEntry{expiresAt: now.Add(-time.Second)}
+	s.cache["fresh"] = &cacheEntry{expiresAt: now.Add(time.Second)}
+
+	userID := primitive.NewObjectID().Hex()
+	_, err := s.GetEntitlements(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.cache["expired"]; ok {
+		t.Error("expired entry was not pruned")
+	}
+	for _, key := range []string{"fresh", userID} {
+		if _, ok := s.cache[key]; !ok {
+			t.Errorf("entry %s was pruned", key)
+		}
+	}
+}
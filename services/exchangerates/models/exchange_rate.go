@@ -0,0 +1,28 @@
+package models
+
+import (
+	"go-subscriptions-workflow/types"
+	"time"
+)
+
+type ExchangeRate struct {
+	ID        string    `bson:"_id"`
+	Base      string    `bson:"base"`
+	Quote     string    `bson:"quote"`
+	Rate      float64   `bson:"rate"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+func NewID(base, quote string) string {
+	return base + "/" + quote
+}
+
+func (r *ExchangeRate) Out() *types.ExchangeRateOutput {
+	return &types.ExchangeRateOutput{
+		ID:        r.ID,
+		Base:      r.Base,
+		Quote:     r.Quote,
+		Rate:      r.Rate,
+		UpdatedAt: r.UpdatedAt,
+	}
+}
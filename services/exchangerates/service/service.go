@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/exchangerates/models"
+	"go-subscriptions-workflow/services/exchangerates/shared"
+	"go-subscriptions-workflow/services/exchangerates/store"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type ExchangeRatesService interface {
+	SetRate(ctx context.Context, in *types.SetExchangeRateInput) (*types.ExchangeRateOutput, error)
+	GetRates(ctx context.Context) ([]*types.ExchangeRateOutput, error)
+	Convert(ctx context.Context, amount money.Money, currency string) (money.Money, error)
+}
+
+type exchangeRatesService struct {
+	exchangeRatesStore store.ExchangeRatesStore
+}
+
+func NewExchangeRatesService(dbConn db.Connection) ExchangeRatesService {
+	return &exchangeRatesService{exchangeRatesStore: store.NewExchangeRatesStore(dbConn.DB())}
+}
+
+func (s *exchangeRatesService) SetRate(ctx context.Context, in *types.SetExchangeRateInput) (*types.ExchangeRateOutput, error) {
+	err := money.ValidateCurrency(in.Base)
+	if err != nil {
+		return nil, err
+	}
+	err = money.ValidateCurrency(in.Quote)
+	if err != nil {
+		return nil, err
+	}
+	if in.Base == in.Quote || in.Rate <= 0 {
+		return nil, fmt.Errorf("invalid exchange rate: base=%v, quote=%v, rate=%v", in.Base, in.Quote, in.Rate)
+	}
+	rate := &models.ExchangeRate{
+		ID:        models.NewID(in.Base, in.Quote),
+		Base:      in.Base,
+		Quote:     in.Quote,
+		Rate:      in.Rate,
+		UpdatedAt: time.Now(),
+	}
+	err = s.exchangeRatesStore.Upsert(ctx, rate)
+	if err != nil {
+		return nil, err
+	}
+	return rate.Out(), nil
+}
+
+func (s *exchangeRatesService) GetRates(ctx context.Context) ([]*types.ExchangeRateOutput, error) {
+	rates, err := s.exchangeRatesStore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.ExchangeRateOutput, 0, len(rates))
+	for index := range rates {
+		out = append(out, rates[index].Out())
+	}
+	return out, nil
+}
+
+func (s *exchangeRatesService) Convert(ctx context.Context, amount money.Money, currency string) (money.Money, error) {
+	if amount.Currency == currency {
+		return amount, nil
+	}
+	rate, err := s.exchangeRatesStore.Get(ctx, models.NewID(amount.Currency, currency))
+	if err == nil {
+		return money.Convert(amount, currency, rate.Rate), nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return amount, err
+	}
+	rate, err = s.exchangeRatesStore.Get(ctx, models.NewID(currency, amount.Currency))
+	if err == mongo.ErrNoDocuments {
+		return amount, fmt.Errorf("%w: base=%v, quote=%v", shared.ErrExchangeRateNotFound, amount.Currency, currency)
+	}
+	if err != nil {
+		return amount, err
+	}
+	return money.Convert(amount, currency, 1/rate.Rate), nil
+}
@@ -0,0 +1,9 @@
+package shared
+
+import (
+	"errors"
+)
+
+var (
+	ErrExchangeRateNotFound = errors.New("exchange rate not found")
+)
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"go-subscriptions-workflow/services/exchangerates/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+)
+
+type ExchangeRatesStore interface {
+	Upsert(ctx context.Context, rate *models.ExchangeRate) error
+	Get(ctx context.Context, id string) (*models.ExchangeRate, error)
+	GetAll(ctx context.Context) ([]*models.ExchangeRate, error)
+}
+
+type exchangeRatesStore struct {
+	coll *mongo.Collection
+}
+
+func NewExchangeRatesStore(dbConn *mongo.Database) ExchangeRatesStore {
+	return &exchangeRatesStore{coll: dbConn.Collection("exchange_rates")}
+}
+
+func (s *exchangeRatesStore) Upsert(ctx context.Context, rate *models.ExchangeRate) error {
+	result, err := s.coll.ReplaceOne(ctx, bson.M{"_id": rate.ID}, rate, options.Replace().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	log.Printf("exchange rate upserted: %+v\n", result)
+	return nil
+}
+
+func (s *exchangeRatesStore) Get(ctx context.Context, id string) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&rate)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (s *exchangeRatesStore) GetAll(ctx context.Context) ([]*models.ExchangeRate, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var rates []*models.ExchangeRate
+	err = cursor.All(ctx, &rates)
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
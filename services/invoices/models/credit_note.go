@@ -0,0 +1,45 @@
+package models
+
+import (
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/invoices/shared"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type CreditNote struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Number          int64              `bson:"number"`
+	RefundID        string             `bson:"refund_id"`
+	Status          string             `bson:"status"`
+	InvoiceID       primitive.ObjectID `bson:"invoice_id"`
+	UserID          primitive.ObjectID `bson:"user_id"`
+	SubscriptionID  primitive.ObjectID `bson:"subscription_id"`
+	Amount          money.Money        `bson:"amount"`
+	Refunded        money.Money        `bson:"refunded"`
+	Reason          string             `bson:"reason"`
+	LedgerReference string             `bson:"ledger_reference"`
+	CreatedAt       time.Time          `bson:"created_at"`
+}
+
+func (c *CreditNote) Pending() bool {
+	return c.Status == shared.CreditNoteStatusPending
+}
+
+func (c *CreditNote) Out() *types.CreditNoteOutput {
+	return &types.CreditNoteOutput{
+		ID:              c.ID.Hex(),
+		Number:          c.Number,
+		RefundID:        c.RefundID,
+		Status:          c.Status,
+		InvoiceID:       c.InvoiceID.Hex(),
+		UserID:          c.UserID.Hex(),
+		SubscriptionID:  c.SubscriptionID.Hex(),
+		Amount:          c.Amount,
+		Refunded:        c.Refunded,
+		Reason:          c.Reason,
+		LedgerReference: c.LedgerReference,
+		CreatedAt:       c.CreatedAt,
+	}
+}
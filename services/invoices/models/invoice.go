@@ -0,0 +1,76 @@
+package models
+
+import (
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Line struct {
+	Type        string      `bson:"type"`
+	Description string      `bson:"description"`
+	Quantity    int64       `bson:"quantity"`
+	Amount      money.Money `bson:"amount"`
+	Inclusive   bool        `bson:"inclusive"`
+}
+
+func (l *Line) Out() *types.InvoiceLineOutput {
+	return &types.InvoiceLineOutput{
+		Type:        l.Type,
+		Description: l.Description,
+		Quantity:    l.Quantity,
+		Amount:      l.Amount,
+		Inclusive:   l.Inclusive,
+	}
+}
+
+type Invoice struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Number          int64              `bson:"number"`
+	UserID          primitive.ObjectID `bson:"user_id"`
+	SubscriptionID  primitive.ObjectID `bson:"subscription_id"`
+	Status          string             `bson:"status"`
+	Lines           []*Line            `bson:"lines"`
+	Subtotal        money.Money        `bson:"subtotal"`
+	Discount        money.Money        `bson:"discount"`
+	Tax             money.Money        `bson:"tax"`
+	Total           money.Money        `bson:"total"`
+	Paid            money.Money        `bson:"paid"`
+	Refunded        money.Money        `bson:"refunded"`
+	PeriodStart     time.Time          `bson:"period_start"`
+	PeriodEnd       time.Time          `bson:"period_end"`
+	LedgerReference string             `bson:"ledger_reference"`
+	Gateway         string             `bson:"gateway"`
+	PaymentID       string             `bson:"payment_id"`
+	CreatedAt       time.Time          `bson:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at"`
+}
+
+func (i *Invoice) Out() *types.InvoiceOutput {
+	out := &types.InvoiceOutput{
+		ID:              i.ID.Hex(),
+		Number:          i.Number,
+		UserID:          i.UserID.Hex(),
+		SubscriptionID:  i.SubscriptionID.Hex(),
+		Status:          i.Status,
+		Subtotal:        i.Subtotal,
+		Discount:        i.Discount,
+		Tax:             i.Tax,
+		Total:           i.Total,
+		Paid:            i.Paid,
+		Refunded:        i.Refunded,
+		PeriodStart:     i.PeriodStart,
+		PeriodEnd:       i.PeriodEnd,
+		LedgerReference: i.LedgerReference,
+		Gateway:         i.Gateway,
+		PaymentID:       i.PaymentID,
+		CreatedAt:       i.CreatedAt,
+		UpdatedAt:       i.UpdatedAt,
+	}
+	out.Lines = make([]*types.InvoiceLineOutput, 0, len(i.Lines))
+	for index := range i.Lines {
+		out.Lines = append(out.Lines, i.Lines[index].Out())
+	}
+	return out
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Receipt struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	InvoiceID primitive.ObjectID `bson:"invoice_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Number    int64              `bson:"number"`
+	HTML      []byte             `bson:"html"`
+	PDF       []byte             `bson:"pdf"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+func (r *Receipt) Out() *types.ReceiptOutput {
+	return &types.ReceiptOutput{
+		ID:        r.ID.Hex(),
+		InvoiceID: r.InvoiceID.Hex(),
+		UserID:    r.UserID.Hex(),
+		Number:    r.Number,
+		HTML:      r.HTML,
+		PDF:       r.PDF,
+		CreatedAt: r.CreatedAt,
+	}
+}
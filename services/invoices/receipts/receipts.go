@@ -0,0 +1,97 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+type Line struct {
+	Description string
+	Quantity    int64
+	Amount      string
+}
+
+// Receipt holds what a receipt shows, already formatted for display.
+type Receipt struct {
+	Number      int64
+	IssuedAt    time.Time
+	Email       string
+	Plan        string
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Lines       []Line
+	Subtotal    string
+	Discount    string
+	Tax         string
+	Total       string
+	Paid        string
+	Balance     string
+}
+
+var htmlTemplate = template.Must(template.New("receipt").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format(dateLayout) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Receipt #{{.Number}}</title>
+</head>
+<body>
+<h1>Receipt #{{.Number}}</h1>
+<p>Issued {{date .IssuedAt}} to {{.Email}}</p>
+<p>Plan: {{.Plan}}</p>
+<p>Period: {{date .PeriodStart}} to {{date .PeriodEnd}}</p>
+<table>
+<tr><th>Description</th><th>Quantity</th><th>Amount</th></tr>
+{{range .Lines}}<tr><td>{{.Description}}</td><td>{{.Quantity}}</td><td>{{.Amount}}</td></tr>
+{{end}}</table>
+<p>Subtotal: {{.Subtotal}}</p>
+<p>Discount: {{.Discount}}</p>
+<p>Tax: {{.Tax}}</p>
+<p>Total: {{.Total}}</p>
+<p>Paid: {{.Paid}}</p>
+<p>Remaining balance: {{.Balance}}</p>
+</body>
+</html>
+`))
+
+func HTML(receipt *Receipt) ([]byte, error) {
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, receipt)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func PDF(receipt *Receipt) []byte {
+	return newPDF(text(receipt))
+}
+
+// text lays the receipt out as the lines of the PDF page.
+func text(receipt *Receipt) []string {
+	lines := []string{
+		fmt.Sprintf("Receipt #%d", receipt.Number),
+		fmt.Sprintf("Issued %s to %s", receipt.IssuedAt.Format(dateLayout), receipt.Email),
+		"",
+		fmt.Sprintf("Plan: %s", receipt.Plan),
+		fmt.Sprintf("Period: %s to %s", receipt.PeriodStart.Format(dateLayout), receipt.PeriodEnd.Format(dateLayout)),
+		"",
+	}
+	for _, line := range receipt.Lines {
+		lines = append(lines, fmt.Sprintf("%s x%d  %s", line.Description, line.Quantity, line.Amount))
+	}
+	return append(lines,
+		"",
+		fmt.Sprintf("Subtotal: %s", receipt.Subtotal),
+		fmt.Sprintf("Discount: %s", receipt.Discount),
+		fmt.Sprintf("Tax: %s", receipt.Tax),
+		fmt.Sprintf("Total: %s", receipt.Total),
+		fmt.Sprintf("Paid: %s", receipt.Paid),
+		fmt.Sprintf("Remaining balance: %s", receipt.Balance),
+	)
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/invoices/models"
+	"go-subscriptions-workflow/services/invoices/receipts"
+	"go-subscriptions-workflow/services/invoices/shared"
+	"go-subscriptions-workflow/services/invoices/store"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"time"
+)
+
+type InvoicesService interface {
+	CreateInvoice(ctx context.Context, in *types.CreateInvoiceInput) (*types.InvoiceOutput, error)
+	GetInvoices(ctx context.Context, userID string) ([]*types.InvoiceOutput, error)
+	GetInvoice(ctx context.Context, in *types.GetInvoiceInput) (*types.InvoiceOutput, error)
+	GetInvoiceByID(ctx context.Context, id string) (*types.InvoiceOutput, error)
+	CreateCreditNote(ctx context.Context, in *types.CreateCreditNoteInput) (*types.CreditNoteOutput, error)
+	CompleteCreditNote(ctx context.Context, id string) (*types.CreditNoteOutput, error)
+	GetCreditNotes(ctx context.Context, in *types.GetInvoiceInput) ([]*types.CreditNoteOutput, error)
+	GetInvoiceByReference(ctx context.Context, ledgerReference string) (*types.InvoiceOutput, error)
+	CreateReceipt(ctx context.Context, in *types.CreateReceiptInput) (*types.ReceiptOutput, error)
+	GetReceipt(ctx context.Context, in *types.GetInvoiceInput) (*types.ReceiptOutput, error)
+}
+
+type invoicesService struct {
+	invoicesStore store.InvoicesStore
+}
+
+func NewInvoicesService(dbConn db.Connection) InvoicesService {
+	return &invoicesService{invoicesStore: store.NewInvoicesStore(dbConn.DB())}
+}
+
+func (s *invoicesService) CreateInvoice(ctx context.Context, in *types.CreateInvoiceInput) (*types.InvoiceOutput, error) {
+	userID, err := primitive.ObjectIDFromHex(in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	subscriptionID, err := primitive.ObjectIDFromHex(in.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	switch in.Status {
+	case shared.StatusPaid, shared.StatusVoid, shared.StatusUncollectible:
+	default:
+		return nil, fmt.Errorf("invalid invoice status: %s", in.Status)
+	}
+	if len(in.Lines) == 0 {
+		return nil, fmt.Errorf("invoice has no lines: subscription_id=%v", in.SubscriptionID)
+	}
+	invoice := &models.Invoice{
+		ID:              primitive.NewObjectID(),
+		UserID:          userID,
+		SubscriptionID:  subscriptionID,
+		Status:          in.Status,
+		Subtotal:        money.Zero(in.Lines[0].Amount.Currency),
+		Discount:        money.Zero(in.Lines[0].Amount.Currency),
+		Tax:             money.Zero(in.Lines[0].Amount.Currency),
+		Paid:            in.Paid,
+		Refunded:        money.Zero(in.Lines[0].Amount.Currency),
+		PeriodStart:     in.PeriodStart,
+		PeriodEnd:       in.PeriodEnd,
+		LedgerReference: in.LedgerReference,
+		Gateway:         in.Gateway,
+		PaymentID:       in.PaymentID,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	exclusiveTax := money.Zero(in.Lines[0].Amount.Currency)
+	for _, line := range in.Lines {
+		switch line.Type {
+		case shared.LineTypeDiscount:
+			invoice.Discount, err = invoice.Discount.Add(line.Amount.Neg())
+		case shared.LineTypeTax:
+			invoice.Tax, err = invoice.Tax.Add(line.Amount)
+			if err == nil && !line.Inclusive {
+				exclusiveTax, err = exclusiveTax.Add(line.Amount)
+			}
+		default:
+			invoice.Subtotal, err = invoice.Subtotal.Add(line.Amount)
+		}
+		if err != nil {
+			return nil, err
+		}
+		invoice.Lines = append(invoice.Lines, &models.Line{
+			Type:        line.Type,
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			Amount:      line.Amount,
+			Inclusive:   line.Inclusive,
+		})
+	}
+	invoice.Total, err = invoice.Subtotal.Sub(invoice.Discount)
+	if err != nil {
+		return nil, err
+	}
+	invoice.Total, err = invoice.Total.Add(exclusiveTax)
+	if err != nil {
+		return nil, err
+	}
+	invoice.Number, err = s.invoicesStore.NextNumber(ctx, store.InvoiceCounter)
+	if err != nil {
+		return nil, err
+	}
+	err = s.invoicesStore.Create(ctx, invoice)
+	if err != nil {
+		return nil, err
+	}
+	return invoice.Out(), nil
+}
+
+func (s *invoicesService) GetInvoices(ctx context.Context, userID string) ([]*types.InvoiceOutput, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	invoices, err := s.invoicesStore.GetByUserID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.InvoiceOutput, 0, len(invoices))
+	for index := range invoices {
+		out = append(out, invoices[index].Out())
+	}
+	return out, nil
+}
+
+func (s *invoicesService) GetInvoice(ctx context.Context, in *types.GetInvoiceInput) (*types.InvoiceOutput, error) {
+	invoice, err := s.getInvoice(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return invoice.Out(), nil
+}
+
+func (s *invoicesService) GetInvoiceByID(ctx context.Context, id string) (*types.InvoiceOutput, error) {
+	invoiceID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := s.invoicesStore.Get(ctx, invoiceID)
+	if err == mongo.ErrNoDocuments {
+		return nil, shared.ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return invoice.Out(), nil
+}
+
+// CreateCreditNote records a pending refund against a paid invoice, and holds
+// its amount so refunds cannot exceed the invoice total. Amount is in the
+// invoice currency and Refunded is what goes back to the user, in the currency
+// the invoice was paid in. Creating it again with the same RefundID returns the
+// credit note created first.
+func (s *invoicesService) CreateCreditNote(ctx context.Context, in *types.CreateCreditNoteInput) (*types.CreditNoteOutput, error) {
+	invoiceID, err := primitive.ObjectIDFromHex(in.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if in.RefundID == "" {
+		return nil, fmt.Errorf("credit note has no refund id: invoice_id=%v", in.InvoiceID)
+	}
+	creditNote, err := s.invoicesStore.GetCreditNoteByRefundID(ctx, in.RefundID)
+	if err == nil {
+		return creditNote.Out(), nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if !in.Amount.IsPositive() || in.Amount.Validate() != nil {
+		return nil, fmt.Errorf("invalid amount to refund: %v", in.Amount)
+	}
+	invoice, err := s.invoicesStore.Get(ctx, invoiceID)
+	if err == mongo.ErrNoDocuments {
+		return nil, shared.ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Status != shared.StatusPaid {
+		return nil, shared.ErrInvoiceNotRefundable
+	}
+	invoice, err = s.invoicesStore.ReserveRefund(ctx, invoiceID, in.RefundID, in.Amount)
+	if err == mongo.ErrNoDocuments {
+		return nil, shared.ErrRefundExceedsTotal
+	}
+	if err != nil {
+		return nil, err
+	}
+	creditNote = &models.CreditNote{
+		ID:              primitive.NewObjectID(),
+		RefundID:        in.RefundID,
+		Status:          shared.CreditNoteStatusPending,
+		InvoiceID:       invoice.ID,
+		UserID:          invoice.UserID,
+		SubscriptionID:  invoice.SubscriptionID,
+		Amount:          in.Amount,
+		Refunded:        in.Refunded,
+		Reason:          in.Reason,
+		LedgerReference: invoice.LedgerReference,
+		CreatedAt:       time.Now(),
+	}
+	creditNote.Number, err = s.invoicesStore.NextNumber(ctx, store.CreditNoteCounter)
+	if err == nil {
+		err = s.invoicesStore.CreateCreditNote(ctx, creditNote)
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		existing, getErr := s.invoicesStore.GetCreditNoteByRefundID(ctx, in.RefundID)
+		if getErr != nil {
+			return nil, getErr
+		}
+		return existing.Out(), nil
+	}
+	if err != nil {
+		releaseErr := s.invoicesStore.ReleaseRefund(ctx, invoiceID, in.RefundID, in.Amount)
+		if releaseErr != nil {
+			log.Printf("release refund failed: invoice_id=%v, refund_id=%v, error=%v\n", in.InvoiceID, in.RefundID, releaseErr)
+		}
+		return nil, err
+	}
+	return creditNote.Out(), nil
+}
+
+// CompleteCreditNote records that the refund of a pending credit note went
+// through, and counts it as refunded on the invoice.
+func (s *invoicesService) CompleteCreditNote(ctx context.Context, id string) (*types.CreditNoteOutput, error) {
+	creditNoteID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	creditNote, err := s.invoicesStore.GetCreditNote(ctx, creditNoteID)
+	if err != nil {
+		return nil, err
+	}
+	if !creditNote.Pending() {
+		return creditNote.Out(), nil
+	}
+	_, err = s.invoicesStore.CompleteRefund(ctx, creditNote.InvoiceID, creditNote.RefundID, creditNote.Amount)
+	if err != nil {
+		return nil, err
+	}
+	creditNote, err = s.invoicesStore.SetCreditNoteStatus(ctx, creditNoteID, shared.CreditNoteStatusRefunded)
+	if err != nil {
+		return nil, err
+	}
+	return creditNote.Out(), nil
+}
+
+func (s *invoicesService) GetCreditNotes(ctx context.Context, in *types.GetInvoiceInput) ([]*types.CreditNoteOutput, error) {
+	invoice, err := s.getInvoice(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	creditNotes, err := s.invoicesStore.GetCreditNotes(ctx, invoice.ID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.CreditNoteOutput, 0, len(creditNotes))
+	for index := range creditNotes {
+		out = append(out, creditNotes[index].Out())
+	}
+	return out, nil
+}
+
+func (s *invoicesService) GetInvoiceByReference(ctx context.Context, ledgerReference string) (*types.InvoiceOutput, error) {
+	invoice, err := s.invoicesStore.GetByLedgerReference(ctx, ledgerReference)
+	if err == mongo.ErrNoDocuments {
+		return nil, shared.ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return invoice.Out(), nil
+}
+
+// CreateReceipt renders the receipt of an invoice as HTML and PDF and stores
+// both for download.
+func (s *invoicesService) CreateReceipt(ctx context.Context, in *types.CreateReceiptInput) (*types.ReceiptOutput, error) {
+	invoiceID, err := primitive.ObjectIDFromHex(in.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := s.invoicesStore.Get(ctx, invoiceID)
+	if err == mongo.ErrNoDocuments {
+		return nil, shared.ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := &receipts.Receipt{
+		Number:      invoice.Number,
+		IssuedAt:    invoice.CreatedAt,
+		Email:       in.Email,
+		Plan:        in.Plan,
+		PeriodStart: invoice.PeriodStart,
+		PeriodEnd:   invoice.PeriodEnd,
+		Subtotal:    invoice.Subtotal.String(),
+		Discount:    invoice.Discount.String(),
+		Tax:         invoice.Tax.String(),
+		Total:       invoice.Total.String(),
+		Paid:        invoice.Paid.String(),
+		Balance:     in.Balance.String(),
+	}
+	for _, line := range invoice.Lines {
+		data.Lines = append(data.Lines, receipts.Line{
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			Amount:      line.Amount.String(),
+		})
+	}
+	receipt := &models.Receipt{
+		ID:        primitive.NewObjectID(),
+		InvoiceID: invoice.ID,
+		UserID:    invoice.UserID,
+		Number:    invoice.Number,
+		PDF:       receipts.PDF(data),
+		CreatedAt: time.Now(),
+	}
+	receipt.HTML, err = receipts.HTML(data)
+	if err != nil {
+		return nil, err
+	}
+	err = s.invoicesStore.SaveReceipt(ctx, receipt)
+	if err != nil {
+		return nil, err
+	}
+	return receipt.Out(), nil
+}
+
+func (s *invoicesService) GetReceipt(ctx context.Context, in *types.GetInvoiceInput) (*types.ReceiptOutput, error) {
+	invoice, err := s.getInvoice(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := s.invoicesStore.GetReceipt(ctx, invoice.ID)
+	if err == mongo.ErrNoDocuments {
+		return nil, shared.ErrReceiptNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return receipt.Out(), nil
+}
+
+func (s *invoicesService) getInvoice(ctx context.Context, in *types.GetInvoiceInput) (*models.Invoice, error) {
+	id, err := primitive.ObjectIDFromHex(in.ID)
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := s.invoicesStore.Get(ctx, id)
+	if err == mongo.ErrNoDocuments {
+		return nil, shared.ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invoice.UserID.Hex() != in.UserID {
+		return nil, shared.ErrInvoiceNotFound
+	}
+	return invoice, nil
+}
@@ -0,0 +1,34 @@
+package shared
+
+import (
+	"errors"
+)
+
+const (
+	StatusPaid          = "paid"
+	StatusVoid          = "void"
+	StatusUncollectible = "uncollectible"
+)
+
+// A credit note stays pending until its refund goes through; only then does it
+// count as refunded on the invoice. Credit notes from before refunds could be
+// pending have no status and were all refunded.
+const (
+	CreditNoteStatusPending  = "pending"
+	CreditNoteStatusRefunded = "refunded"
+)
+
+const (
+	LineTypePlan      = "plan"
+	LineTypeProration = "proration"
+	LineTypeDiscount  = "discount"
+	LineTypeUsage     = "usage"
+	LineTypeTax       = "tax"
+)
+
+var (
+	ErrInvoiceNotFound      = errors.New("invoice not found")
+	ErrRefundExceedsTotal   = errors.New("refund exceeds invoice total")
+	ErrInvoiceNotRefundable = errors.New("invoice is not refundable")
+	ErrReceiptNotFound      = errors.New("receipt not found")
+)
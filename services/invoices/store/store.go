@@ -0,0 +1,294 @@
+package store
+
+import (
+	"context"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/invoices/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+const (
+	InvoiceCounter    = "invoices"
+	CreditNoteCounter = "credit_notes"
+)
+
+type InvoicesStore interface {
+	Create(ctx context.Context, invoice *models.Invoice) error
+	Update(ctx context.Context, invoice *models.Invoice) error
+	ReserveRefund(ctx context.Context, id primitive.ObjectID, refundID string, amount money.Money) (*models.Invoice, error)
+	ReleaseRefund(ctx context.Context, id primitive.ObjectID, refundID string, amount money.Money) error
+	CompleteRefund(ctx context.Context, id primitive.ObjectID, refundID string, amount money.Money) (*models.Invoice, error)
+	NextNumber(ctx context.Context, counter string) (int64, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.Invoice, error)
+	GetByUserID(ctx context.Context, userID primitive.ObjectID) ([]*models.Invoice, error)
+	GetByLedgerReference(ctx context.Context, ledgerReference string) (*models.Invoice, error)
+	CreateCreditNote(ctx context.Context, creditNote *models.CreditNote) error
+	SetCreditNoteStatus(ctx context.Context, id primitive.ObjectID, status string) (*models.CreditNote, error)
+	GetCreditNote(ctx context.Context, id primitive.ObjectID) (*models.CreditNote, error)
+	GetCreditNoteByRefundID(ctx context.Context, refundID string) (*models.CreditNote, error)
+	GetCreditNotes(ctx context.Context, invoiceID primitive.ObjectID) ([]*models.CreditNote, error)
+	SaveReceipt(ctx context.Context, receipt *models.Receipt) error
+	GetReceipt(ctx context.Context, invoiceID primitive.ObjectID) (*models.Receipt, error)
+}
+
+type invoicesStore struct {
+	coll        *mongo.Collection
+	creditNotes *mongo.Collection
+	receipts    *mongo.Collection
+	counters    *mongo.Collection
+}
+
+func NewInvoicesStore(dbConn *mongo.Database) InvoicesStore {
+	return &invoicesStore{
+		coll:        dbConn.Collection("invoices"),
+		creditNotes: dbConn.Collection("credit_notes"),
+		receipts:    dbConn.Collection("receipts"),
+		counters:    dbConn.Collection("counters"),
+	}
+}
+
+func (s *invoicesStore) Create(ctx context.Context, invoice *models.Invoice) error {
+	result, err := s.coll.InsertOne(ctx, invoice)
+	if err != nil {
+		return err
+	}
+	log.Printf("invoice created: %+v\n", result)
+	return nil
+}
+
+func (s *invoicesStore) Update(ctx context.Context, invoice *models.Invoice) error {
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":     invoice.Status,
+			"updated_at": invoice.UpdatedAt,
+		},
+	}
+
+	result, err := s.coll.UpdateByID(ctx, invoice.ID, update)
+	if err != nil {
+		return err
+	}
+	log.Printf("invoice updated: %+v\n", result)
+	return nil
+}
+
+// ReserveRefund holds amount for a refund while it is pending, only while the
+// refunds held and made stay within the invoice total. Reserving the same
+// refund again finds it held; otherwise it returns mongo.ErrNoDocuments.
+func (s *invoicesStore) ReserveRefund(ctx context.Context, id primitive.ObjectID, refundID string, amount money.Money) (*models.Invoice, error) {
+	refunded := bson.M{"$ifNull": bson.A{"$refunded.amount", 0}}
+	pending := bson.M{"$ifNull": bson.A{"$pending_refund.amount", 0}}
+	filter := bson.M{
+		"_id":            id,
+		"total.currency": amount.Currency,
+		"refund_ids":     bson.M{"$ne": refundID},
+		"$expr":          bson.M{"$lte": bson.A{bson.M{"$add": bson.A{refunded, pending, amount.Amount}}, "$total.amount"}},
+	}
+	update := bson.M{
+		"$inc":  bson.M{"pending_refund.amount": amount.Amount},
+		"$set":  bson.M{"pending_refund.currency": amount.Currency, "updated_at": time.Now()},
+		"$push": bson.M{"refund_ids": refundID},
+	}
+	invoice, err := s.findOneAndUpdate(ctx, filter, update)
+	if err == mongo.ErrNoDocuments {
+		return s.findOne(ctx, bson.M{"_id": id, "refund_ids": refundID})
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("invoice refund reserved: id=%v, refund_id=%v, amount=%v\n", invoice.ID.Hex(), refundID, amount)
+	return invoice, nil
+}
+
+// ReleaseRefund gives back the amount held for a refund that was never
+// recorded.
+func (s *invoicesStore) ReleaseRefund(ctx context.Context, id primitive.ObjectID, refundID string, amount money.Money) error {
+	filter := bson.M{"_id": id, "refund_ids": refundID, "refunded_ids": bson.M{"$ne": refundID}}
+	update := bson.M{
+		"$inc":  bson.M{"pending_refund.amount": -amount.Amount},
+		"$set":  bson.M{"updated_at": time.Now()},
+		"$pull": bson.M{"refund_ids": refundID},
+	}
+	result, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	log.Printf("invoice refund released: %+v\n", result)
+	return nil
+}
+
+// CompleteRefund moves the amount held for a refund into the refunded total,
+// once per refund.
+func (s *invoicesStore) CompleteRefund(ctx context.Context, id primitive.ObjectID, refundID string, amount money.Money) (*models.Invoice, error) {
+	filter := bson.M{"_id": id, "refund_ids": refundID, "refunded_ids": bson.M{"$ne": refundID}}
+	update := bson.M{
+		"$inc":  bson.M{"pending_refund.amount": -amount.Amount, "refunded.amount": amount.Amount},
+		"$set":  bson.M{"refunded.currency": amount.Currency, "updated_at": time.Now()},
+		"$push": bson.M{"refunded_ids": refundID},
+	}
+	invoice, err := s.findOneAndUpdate(ctx, filter, update)
+	if err == mongo.ErrNoDocuments {
+		return s.findOne(ctx, bson.M{"_id": id, "refunded_ids": refundID})
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("invoice refunded: id=%v, refund_id=%v, refunded=%v\n", invoice.ID.Hex(), refundID, invoice.Refunded)
+	return invoice, nil
+}
+
+func (s *invoicesStore) findOneAndUpdate(ctx context.Context, filter, update bson.M) (*models.Invoice, error) {
+	var invoice models.Invoice
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&invoice)
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (s *invoicesStore) findOne(ctx context.Context, filter bson.M) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := s.coll.FindOne(ctx, filter).Decode(&invoice)
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (s *invoicesStore) NextNumber(ctx context.Context, counter string) (int64, error) {
+	var next struct {
+		Seq int64 `bson:"seq"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	update := bson.M{"$inc": bson.M{"seq": int64(1)}}
+	err := s.counters.FindOneAndUpdate(ctx, bson.M{"_id": counter}, update, opts).Decode(&next)
+	if err != nil {
+		return 0, err
+	}
+	return next.Seq, nil
+}
+
+func (s *invoicesStore) Get(ctx context.Context, id primitive.ObjectID) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&invoice)
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (s *invoicesStore) GetByLedgerReference(ctx context.Context, ledgerReference string) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := s.coll.FindOne(ctx, bson.M{"ledger_reference": ledgerReference}).Decode(&invoice)
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (s *invoicesStore) GetByUserID(ctx context.Context, userID primitive.ObjectID) ([]*models.Invoice, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "number", Value: -1}})
+	cursor, err := s.coll.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var invoices []*models.Invoice
+	err = cursor.All(ctx, &invoices)
+	if err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+func (s *invoicesStore) CreateCreditNote(ctx context.Context, creditNote *models.CreditNote) error {
+	result, err := s.creditNotes.InsertOne(ctx, creditNote)
+	if err != nil {
+		return err
+	}
+	log.Printf("credit note created: %+v\n", result)
+	return nil
+}
+
+func (s *invoicesStore) SetCreditNoteStatus(ctx context.Context, id primitive.ObjectID, status string) (*models.CreditNote, error) {
+	update := bson.M{"$set": bson.M{"status": status}}
+	var creditNote models.CreditNote
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.creditNotes.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&creditNote)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("credit note updated: id=%v, status=%v\n", creditNote.ID.Hex(), creditNote.Status)
+	return &creditNote, nil
+}
+
+func (s *invoicesStore) GetCreditNote(ctx context.Context, id primitive.ObjectID) (*models.CreditNote, error) {
+	var creditNote models.CreditNote
+	err := s.creditNotes.FindOne(ctx, bson.M{"_id": id}).Decode(&creditNote)
+	if err != nil {
+		return nil, err
+	}
+	return &creditNote, nil
+}
+
+func (s *invoicesStore) GetCreditNoteByRefundID(ctx context.Context, refundID string) (*models.CreditNote, error) {
+	var creditNote models.CreditNote
+	err := s.creditNotes.FindOne(ctx, bson.M{"refund_id": refundID}).Decode(&creditNote)
+	if err != nil {
+		return nil, err
+	}
+	return &creditNote, nil
+}
+
+func (s *invoicesStore) GetCreditNotes(ctx context.Context, invoiceID primitive.ObjectID) ([]*models.CreditNote, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "number", Value: 1}})
+	cursor, err := s.creditNotes.Find(ctx, bson.M{"invoice_id": invoiceID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var creditNotes []*models.CreditNote
+	err = cursor.All(ctx, &creditNotes)
+	if err != nil {
+		return nil, err
+	}
+	return creditNotes, nil
+}
+
+// SaveReceipt stores the receipt of an invoice, replacing the one rendered
+// before, so rendering it again is safe.
+func (s *invoicesStore) SaveReceipt(ctx context.Context, receipt *models.Receipt) error {
+	update := bson.M{
+		"$set": bson.M{
+			"user_id":    receipt.UserID,
+			"number":     receipt.Number,
+			"html":       receipt.HTML,
+			"pdf":        receipt.PDF,
+			"created_at": receipt.CreatedAt,
+		},
+		"$setOnInsert": bson.M{"_id": receipt.ID},
+	}
+	opts := options.Update().SetUpsert(true)
+	result, err := s.receipts.UpdateOne(ctx, bson.M{"invoice_id": receipt.InvoiceID}, update, opts)
+	if err != nil {
+		return err
+	}
+	log.Printf("receipt saved: %+v\n", result)
+	return nil
+}
+
+func (s *invoicesStore) GetReceipt(ctx context.Context, invoiceID primitive.ObjectID) (*models.Receipt, error) {
+	var receipt models.Receipt
+	err := s.receipts.FindOne(ctx, bson.M{"invoice_id": invoiceID}).Decode(&receipt)
+	if err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
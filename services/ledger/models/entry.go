@@ -0,0 +1,30 @@
+package models
+
+import (
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Entry struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	TransactionID primitive.ObjectID `bson:"transaction_id"`
+	Account       string             `bson:"account"`
+	Amount        money.Money        `bson:"amount"`
+	Reason        string             `bson:"reason"`
+	ReferenceID   string             `bson:"reference_id"`
+	CreatedAt     time.Time          `bson:"created_at"`
+}
+
+func (e *Entry) Out() *types.LedgerEntryOutput {
+	return &types.LedgerEntryOutput{
+		ID:            e.ID.Hex(),
+		TransactionID: e.TransactionID.Hex(),
+		Account:       e.Account,
+		Amount:        e.Amount,
+		Reason:        e.Reason,
+		ReferenceID:   e.ReferenceID,
+		CreatedAt:     e.CreatedAt,
+	}
+}
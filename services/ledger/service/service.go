@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/ledger/models"
+	"go-subscriptions-workflow/services/ledger/shared"
+	"go-subscriptions-workflow/services/ledger/store"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type LedgerService interface {
+	Post(ctx context.Context, in *types.PostTransactionInput) ([]*types.LedgerEntryOutput, error)
+	Void(ctx context.Context, transactionID string) error
+	GetEntries(ctx context.Context, account string) ([]*types.LedgerEntryOutput, error)
+	GetEntriesByReference(ctx context.Context, account, referenceID string) ([]*types.LedgerEntryOutput, error)
+	GetBalances(ctx context.Context, account string) ([]money.Money, error)
+}
+
+type ledgerService struct {
+	ledgerStore store.LedgerStore
+}
+
+func NewLedgerService(dbConn db.Connection) LedgerService {
+	return &ledgerService{ledgerStore: store.NewLedgerStore(dbConn.DB())}
+}
+
+// Post records a balanced transaction: Amount is added to Account and the same
+// amount is taken from the counter account implied by Reason. Charges and
+// refunds are unique per account and ReferenceID; posting one again returns
+// shared.ErrDuplicateReference.
+func (s *ledgerService) Post(ctx context.Context, in *types.PostTransactionInput) ([]*types.LedgerEntryOutput, error) {
+	if in.Amount.IsZero() || in.Amount.Validate() != nil {
+		return nil, fmt.Errorf("invalid amount to post: %v", in.Amount)
+	}
+	counterAccount, err := shared.CounterAccount(in.Reason)
+	if err != nil {
+		return nil, err
+	}
+	transactionID := primitive.NewObjectID()
+	createdAt := time.Now()
+	entries := []*models.Entry{
+		{
+			ID:            primitive.NewObjectID(),
+			TransactionID: transactionID,
+			Account:       in.Account,
+			Amount:        in.Amount,
+			Reason:        in.Reason,
+			ReferenceID:   in.ReferenceID,
+			CreatedAt:     createdAt,
+		},
+		{
+			ID:            primitive.NewObjectID(),
+			TransactionID: transactionID,
+			Account:       counterAccount,
+			Amount:        in.Amount.Neg(),
+			Reason:        in.Reason,
+			ReferenceID:   in.ReferenceID,
+			CreatedAt:     createdAt,
+		},
+	}
+	err = s.ledgerStore.Append(ctx, entries)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, shared.ErrDuplicateReference
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.LedgerEntryOutput, 0, len(entries))
+	for index := range entries {
+		out = append(out, entries[index].Out())
+	}
+	return out, nil
+}
+
+// Void removes a transaction whose balance change did not go through.
+func (s *ledgerService) Void(ctx context.Context, transactionID string) error {
+	id, err := primitive.ObjectIDFromHex(transactionID)
+	if err != nil {
+		return err
+	}
+	return s.ledgerStore.DeleteTransaction(ctx, id)
+}
+
+func (s *ledgerService) GetEntries(ctx context.Context, account string) ([]*types.LedgerEntryOutput, error) {
+	entries, err := s.ledgerStore.GetByAccount(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.LedgerEntryOutput, 0, len(entries))
+	for index := range entries {
+		out = append(out, entries[index].Out())
+	}
+	return out, nil
+}
+
+func (s *ledgerService) GetEntriesByReference(ctx context.Context, account, referenceID string) ([]*types.LedgerEntryOutput, error) {
+	entries, err := s.ledgerStore.GetByReference(ctx, account, referenceID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.LedgerEntryOutput, 0, len(entries))
+	for index := range entries {
+		out = append(out, entries[index].Out())
+	}
+	return out, nil
+}
+
+func (s *ledgerService) GetBalances(ctx context.Context, account string) ([]money.Money, error) {
+	return s.ledgerStore.GetBalances(ctx, account)
+}
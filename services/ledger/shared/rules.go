@@ -0,0 +1,40 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDuplicateReference is returned when a charge or refund is posted again
+// under a reference the account already has one for.
+var ErrDuplicateReference = errors.New("ledger reference already posted")
+
+const (
+	ReasonTopUp              = "top_up"
+	ReasonSubscriptionCharge = "subscription_charge"
+	ReasonRefund             = "refund"
+	ReasonAdjustment         = "adjustment"
+)
+
+const (
+	AccountTopUps      = "system:top_ups"
+	AccountRevenue     = "system:revenue"
+	AccountAdjustments = "system:adjustments"
+)
+
+func UserAccount(userID string) string {
+	return fmt.Sprintf("user:%s", userID)
+}
+
+func CounterAccount(reason string) (string, error) {
+	switch reason {
+	case ReasonTopUp:
+		return AccountTopUps, nil
+	case ReasonSubscriptionCharge, ReasonRefund:
+		return AccountRevenue, nil
+	case ReasonAdjustment:
+		return AccountAdjustments, nil
+	default:
+		return "", fmt.Errorf("invalid ledger reason: %s", reason)
+	}
+}
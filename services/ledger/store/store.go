@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/ledger/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+)
+
+type LedgerStore interface {
+	Append(ctx context.Context, entries []*models.Entry) error
+	DeleteTransaction(ctx context.Context, transactionID primitive.ObjectID) error
+	GetByAccount(ctx context.Context, account string) ([]*models.Entry, error)
+	GetByReference(ctx context.Context, account, referenceID string) ([]*models.Entry, error)
+	GetBalances(ctx context.Context, account string) ([]money.Money, error)
+}
+
+type ledgerStore struct {
+	coll *mongo.Collection
+}
+
+func NewLedgerStore(dbConn *mongo.Database) LedgerStore {
+	return &ledgerStore{coll: dbConn.Collection("ledger_entries")}
+}
+
+func (s *ledgerStore) Append(ctx context.Context, entries []*models.Entry) error {
+	documents := make([]interface{}, 0, len(entries))
+	for index := range entries {
+		documents = append(documents, entries[index])
+	}
+	result, err := s.coll.InsertMany(ctx, documents)
+	if err != nil {
+		return err
+	}
+	log.Printf("ledger entries created: %+v\n", result)
+	return nil
+}
+
+func (s *ledgerStore) DeleteTransaction(ctx context.Context, transactionID primitive.ObjectID) error {
+	result, err := s.coll.DeleteMany(ctx, bson.M{"transaction_id": transactionID})
+	if err != nil {
+		return err
+	}
+	log.Printf("ledger entries deleted: transaction_id=%v, %+v\n", transactionID.Hex(), result)
+	return nil
+}
+
+func (s *ledgerStore) GetByAccount(ctx context.Context, account string) ([]*models.Entry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := s.coll.Find(ctx, bson.M{"account": account}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var entries []*models.Entry
+	err = cursor.All(ctx, &entries)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *ledgerStore) GetByReference(ctx context.Context, account, referenceID string) ([]*models.Entry, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{"account": account, "reference_id": referenceID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var entries []*models.Entry
+	err = cursor.All(ctx, &entries)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *ledgerStore) GetBalances(ctx context.Context, account string) ([]money.Money, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"account": account}}},
+		{{Key: "$group", Value: bson.M{"_id": "$amount.currency", "amount": bson.M{"$sum": "$amount.amount"}}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "currency": "$_id", "amount": 1}}},
+		{{Key: "$sort", Value: bson.M{"currency": 1}}},
+	}
+	cursor, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var balances []money.Money
+	err = cursor.All(ctx, &balances)
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
@@ -0,0 +1,184 @@
+package balance
+
+import (
+	"context"
+	"errors"
+	"go-subscriptions-workflow/money"
+	exchangeratessvc "go-subscriptions-workflow/services/exchangerates/service"
+	exchangeratesshared "go-subscriptions-workflow/services/exchangerates/shared"
+	ledgersvc "go-subscriptions-workflow/services/ledger/service"
+	ledgershared "go-subscriptions-workflow/services/ledger/shared"
+	"go-subscriptions-workflow/services/payments/gateway"
+	"go-subscriptions-workflow/services/payments/shared"
+	userssvc "go-subscriptions-workflow/services/users/service"
+	usersshared "go-subscriptions-workflow/services/users/shared"
+	"go-subscriptions-workflow/types"
+	"time"
+)
+
+// balanceGateway pays from the user's internal balances. The idempotency key is
+// used as the ledger reference, which is unique per charge and refund, so a
+// repeated capture or refund is rejected by the ledger and returns the entry it
+// already posted.
+type balanceGateway struct {
+	usersService         userssvc.UsersService
+	exchangeRatesService exchangeratessvc.ExchangeRatesService
+	ledgerService        ledgersvc.LedgerService
+}
+
+func NewGateway(usersService userssvc.UsersService, exchangeRatesService exchangeratessvc.ExchangeRatesService, ledgerService ledgersvc.LedgerService) gateway.PaymentGateway {
+	return &balanceGateway{
+		usersService:         usersService,
+		exchangeRatesService: exchangeRatesService,
+		ledgerService:        ledgerService,
+	}
+}
+
+func (g *balanceGateway) Name() string {
+	return shared.GatewayBalance
+}
+
+// Authorize picks the first balance that covers the amount, converted into its
+// currency. Balances are not held; Capture debits them atomically.
+func (g *balanceGateway) Authorize(ctx context.Context, in *types.AuthorizePaymentInput) (*types.PaymentOutput, error) {
+	captured, err := g.posted(ctx, in.UserID, in.IdempotencyKey, ledgershared.ReasonSubscriptionCharge)
+	if err != nil || captured != nil {
+		return captured, err
+	}
+	user, err := g.usersService.GetUser(ctx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, balance := range fundingBalances(user, in.Amount.Currency) {
+		due, err := g.exchangeRatesService.Convert(ctx, in.Amount, balance.Currency)
+		if errors.Is(err, exchangeratesshared.ErrExchangeRateNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if balance.Amount >= due.Amount {
+			return &types.PaymentOutput{
+				ID:        in.IdempotencyKey,
+				Gateway:   shared.GatewayBalance,
+				Status:    shared.StatusAuthorized,
+				Amount:    due,
+				CreatedAt: time.Now(),
+			}, nil
+		}
+	}
+	return nil, shared.ErrInsufficientFunds
+}
+
+func (g *balanceGateway) Capture(ctx context.Context, in *types.CapturePaymentInput) (*types.PaymentOutput, error) {
+	debit := new(types.DebitInput)
+	debit.UserID = in.UserID
+	debit.Amount = in.Amount
+	debit.Reason = ledgershared.ReasonSubscriptionCharge
+	debit.ReferenceID = in.IdempotencyKey
+	_, err := g.usersService.Debit(ctx, debit)
+	if errors.Is(err, ledgershared.ErrDuplicateReference) {
+		return g.duplicate(ctx, in.UserID, in.IdempotencyKey, ledgershared.ReasonSubscriptionCharge)
+	}
+	if errors.Is(err, usersshared.ErrInsufficientBalance) {
+		return nil, shared.ErrInsufficientFunds
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &types.PaymentOutput{
+		ID:        in.IdempotencyKey,
+		Gateway:   shared.GatewayBalance,
+		Status:    shared.StatusCaptured,
+		Amount:    in.Amount,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (g *balanceGateway) Refund(ctx context.Context, in *types.RefundPaymentInput) (*types.PaymentOutput, error) {
+	credit := &types.CreditInput{
+		UserID:      in.UserID,
+		Amount:      in.Amount,
+		Reason:      ledgershared.ReasonRefund,
+		ReferenceID: in.IdempotencyKey,
+	}
+	_, err := g.usersService.Credit(ctx, credit)
+	if errors.Is(err, ledgershared.ErrDuplicateReference) {
+		return g.duplicate(ctx, in.UserID, in.IdempotencyKey, ledgershared.ReasonRefund)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &types.PaymentOutput{
+		ID:        in.IdempotencyKey,
+		Gateway:   shared.GatewayBalance,
+		Status:    shared.StatusRefunded,
+		Amount:    in.Amount,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// duplicate returns the payment the ledger already has under key. The entry can
+// be gone again if the payment that posted it was voided, so that is an error.
+func (g *balanceGateway) duplicate(ctx context.Context, userID, key, reason string) (*types.PaymentOutput, error) {
+	out, err := g.posted(ctx, userID, key, reason)
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, ledgershared.ErrDuplicateReference
+	}
+	return out, nil
+}
+
+// posted returns the payment already posted to the ledger under key, if any.
+func (g *balanceGateway) posted(ctx context.Context, userID, key, reason string) (*types.PaymentOutput, error) {
+	entries, err := g.ledgerService.GetEntriesByReference(ctx, ledgershared.UserAccount(userID), key)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Reason != reason {
+			continue
+		}
+		out := &types.PaymentOutput{
+			ID:        key,
+			Gateway:   shared.GatewayBalance,
+			Status:    shared.StatusCaptured,
+			Amount:    entry.Amount.Neg(),
+			CreatedAt: entry.CreatedAt,
+		}
+		if reason == ledgershared.ReasonRefund {
+			out.Status = shared.StatusRefunded
+			out.Amount = entry.Amount
+		}
+		return out, nil
+	}
+	return nil, nil
+}
+
+func fundingBalances(user *types.UserOutput, currency string) []money.Money {
+	balances := make([]money.Money, 0, len(user.Balances))
+	for _, preferred := range []string{currency, user.Currency} {
+		for index := range user.Balances {
+			if user.Balances[index].Currency == preferred && !hasCurrency(balances, preferred) {
+				balances = append(balances, user.Balances[index])
+			}
+		}
+	}
+	for index := range user.Balances {
+		if !hasCurrency(balances, user.Balances[index].Currency) {
+			balances = append(balances, user.Balances[index])
+		}
+	}
+	return balances
+}
+
+func hasCurrency(balances []money.Money, currency string) bool {
+	for index := range balances {
+		if balances[index].Currency == currency {
+			return true
+		}
+	}
+	return false
+}
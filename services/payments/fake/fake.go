@@ -0,0 +1,185 @@
+package fake
+
+import (
+	"context"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/payments/gateway"
+	"go-subscriptions-workflow/services/payments/shared"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"time"
+)
+
+// Sources with a scripted outcome in DefaultScenarios. Any other source is
+// approved.
+const (
+	SourceDeclined          = "tok_declined"
+	SourceInsufficientFunds = "tok_insufficient_funds"
+	SourceCaptureDeclined   = "tok_capture_declined"
+	SourceRefundDeclined    = "tok_refund_declined"
+)
+
+type Scenario struct {
+	AuthorizeError error
+	CaptureError   error
+	RefundError    error
+}
+
+func DefaultScenarios() map[string]Scenario {
+	return map[string]Scenario{
+		SourceDeclined:          {AuthorizeError: shared.ErrPaymentDeclined},
+		SourceInsufficientFunds: {AuthorizeError: shared.ErrInsufficientFunds},
+		SourceCaptureDeclined:   {CaptureError: shared.ErrPaymentDeclined},
+		SourceRefundDeclined:    {RefundError: shared.ErrPaymentDeclined},
+	}
+}
+
+type payment struct {
+	id         string
+	source     string
+	authorized money.Money
+	captured   money.Money
+	refunded   money.Money
+	status     string
+	createdAt  time.Time
+}
+
+func (p *payment) out() *types.PaymentOutput {
+	return &types.PaymentOutput{
+		ID:        p.id,
+		Gateway:   shared.GatewayFake,
+		Status:    p.status,
+		Amount:    p.authorized,
+		CreatedAt: p.createdAt,
+	}
+}
+
+type result struct {
+	out *types.PaymentOutput
+	err error
+}
+
+// fakeGateway keeps payments in memory, for development and tests. It only
+// works within a single process: payments are lost on restart, and capturing or
+// refunding one then fails with shared.ErrPaymentLost rather than a decline.
+type fakeGateway struct {
+	mu        sync.Mutex
+	scenarios map[string]Scenario
+	payments  map[string]*payment
+	results   map[string]result
+}
+
+func NewGateway(scenarios map[string]Scenario) gateway.PaymentGateway {
+	return &fakeGateway{
+		scenarios: scenarios,
+		payments:  make(map[string]*payment),
+		results:   make(map[string]result),
+	}
+}
+
+func (g *fakeGateway) Name() string {
+	return shared.GatewayFake
+}
+
+func (g *fakeGateway) Authorize(ctx context.Context, in *types.AuthorizePaymentInput) (*types.PaymentOutput, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.once("authorize:"+in.IdempotencyKey, func() (*types.PaymentOutput, error) {
+		err := g.scenarios[in.Source].AuthorizeError
+		if err != nil {
+			return nil, err
+		}
+		p := &payment{
+			id:         "fake_" + primitive.NewObjectID().Hex(),
+			source:     in.Source,
+			authorized: in.Amount,
+			captured:   money.Zero(in.Amount.Currency),
+			refunded:   money.Zero(in.Amount.Currency),
+			status:     shared.StatusAuthorized,
+			createdAt:  time.Now(),
+		}
+		g.payments[p.id] = p
+		return p.out(), nil
+	})
+}
+
+func (g *fakeGateway) Capture(ctx context.Context, in *types.CapturePaymentInput) (*types.PaymentOutput, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.once("capture:"+in.IdempotencyKey, func() (*types.PaymentOutput, error) {
+		p, ok := g.payments[in.PaymentID]
+		if !ok {
+			return nil, shared.ErrPaymentLost
+		}
+		if p.status != shared.StatusAuthorized {
+			return nil, shared.ErrPaymentNotFound
+		}
+		err := g.scenarios[p.source].CaptureError
+		if err != nil {
+			return nil, err
+		}
+		cmp, err := in.Amount.Cmp(p.authorized)
+		if err != nil {
+			return nil, err
+		}
+		if cmp > 0 {
+			return nil, shared.ErrPaymentDeclined
+		}
+		p.captured = in.Amount
+		p.status = shared.StatusCaptured
+		out := p.out()
+		out.Amount = p.captured
+		return out, nil
+	})
+}
+
+func (g *fakeGateway) Refund(ctx context.Context, in *types.RefundPaymentInput) (*types.PaymentOutput, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.once("refund:"+in.IdempotencyKey, func() (*types.PaymentOutput, error) {
+		p, ok := g.payments[in.PaymentID]
+		if !ok {
+			return nil, shared.ErrPaymentLost
+		}
+		if p.status == shared.StatusAuthorized {
+			return nil, shared.ErrPaymentNotFound
+		}
+		err := g.scenarios[p.source].RefundError
+		if err != nil {
+			return nil, err
+		}
+		refunded, err := p.refunded.Add(in.Amount)
+		if err != nil {
+			return nil, err
+		}
+		cmp, err := refunded.Cmp(p.captured)
+		if err != nil {
+			return nil, err
+		}
+		if cmp > 0 {
+			return nil, shared.ErrRefundExceedsTotal
+		}
+		p.refunded = refunded
+		if cmp == 0 {
+			p.status = shared.StatusRefunded
+		}
+		return &types.PaymentOutput{
+			ID:        "fake_" + primitive.NewObjectID().Hex(),
+			Gateway:   shared.GatewayFake,
+			Status:    shared.StatusRefunded,
+			Amount:    in.Amount,
+			CreatedAt: time.Now(),
+		}, nil
+	})
+}
+
+// once replays the outcome recorded for key, declines included.
+func (g *fakeGateway) once(key string, fn func() (*types.PaymentOutput, error)) (*types.PaymentOutput, error) {
+	if r, ok := g.results[key]; ok {
+		return r.out, r.err
+	}
+	out, err := fn()
+	g.results[key] = result{out: out, err: err}
+	return out, err
+}
@@ -0,0 +1,36 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/services/payments/shared"
+	"go-subscriptions-workflow/types"
+)
+
+// PaymentGateway collects payments in two steps, authorize then capture, and
+// refunds captured ones. Calls repeated with the same idempotency key return the
+// original result instead of moving money again.
+type PaymentGateway interface {
+	Name() string
+	Authorize(ctx context.Context, in *types.AuthorizePaymentInput) (*types.PaymentOutput, error)
+	Capture(ctx context.Context, in *types.CapturePaymentInput) (*types.PaymentOutput, error)
+	Refund(ctx context.Context, in *types.RefundPaymentInput) (*types.PaymentOutput, error)
+}
+
+type Gateways map[string]PaymentGateway
+
+func NewGateways(gateways ...PaymentGateway) Gateways {
+	registry := make(Gateways, len(gateways))
+	for _, gateway := range gateways {
+		registry[gateway.Name()] = gateway
+	}
+	return registry
+}
+
+func (g Gateways) Get(name string) (PaymentGateway, error) {
+	gateway, ok := g[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", shared.ErrUnknownGateway, name)
+	}
+	return gateway, nil
+}
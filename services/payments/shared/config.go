@@ -0,0 +1,35 @@
+package shared
+
+import (
+	"go-subscriptions-workflow/util"
+	"os"
+	"strconv"
+)
+
+var fakeGateway = false
+
+func LoadConfigFromEnv() {
+	if value := os.Getenv("PAYMENTS_FAKE_GATEWAY"); value != "" {
+		var err error
+		fakeGateway, err = strconv.ParseBool(value)
+		util.PanicOnError(err)
+	}
+}
+
+// FakeGatewayEnabled reports whether the scripted fake gateway may be used. It
+// approves any source, so it is only meant for development and tests.
+func FakeGatewayEnabled() bool {
+	return fakeGateway
+}
+
+// GatewayEnabled reports whether payment methods may use the named gateway.
+func GatewayEnabled(name string) bool {
+	switch name {
+	case GatewayBalance:
+		return true
+	case GatewayFake:
+		return fakeGateway
+	default:
+		return false
+	}
+}
@@ -0,0 +1,26 @@
+package shared
+
+import (
+	"errors"
+)
+
+const (
+	GatewayBalance = "balance"
+	GatewayFake    = "fake"
+)
+
+const (
+	StatusAuthorized = "authorized"
+	StatusCaptured   = "captured"
+	StatusRefunded   = "refunded"
+)
+
+var (
+	ErrPaymentDeclined    = errors.New("payment declined")
+	ErrInsufficientFunds  = errors.New("insufficient funds")
+	ErrPaymentNotFound    = errors.New("payment not found")
+	ErrPaymentLost        = errors.New("payment lost by fake gateway")
+	ErrRefundExceedsTotal = errors.New("refund exceeds captured amount")
+	ErrUnknownGateway     = errors.New("unknown payment gateway")
+	ErrGatewayDisabled    = errors.New("payment gateway disabled")
+)
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Feature struct {
+	Name string
+}
+
+func (f *Feature) Out() *types.FeatureOutput {
+	return &types.FeatureOutput{Name: f.Name}
+}
+
+type Tier struct {
+	UpTo      int64       `bson:"up_to"`
+	UnitPrice money.Money `bson:"unit_price"`
+	FlatPrice money.Money `bson:"flat_price"`
+}
+
+func (t *Tier) Out() *types.TierOutput {
+	return &types.TierOutput{
+		UpTo:      t.UpTo,
+		UnitPrice: t.UnitPrice,
+		FlatPrice: t.FlatPrice,
+	}
+}
+
+type MeteredPrice struct {
+	Feature   string      `bson:"feature"`
+	Pricing   string      `bson:"pricing"`
+	UnitPrice money.Money `bson:"unit_price"`
+	Tiers     []*Tier     `bson:"tiers"`
+}
+
+func (m *MeteredPrice) Out() *types.MeteredPriceOutput {
+	out := &types.MeteredPriceOutput{
+		Feature:   m.Feature,
+		Pricing:   m.Pricing,
+		UnitPrice: m.UnitPrice,
+	}
+	out.Tiers = make([]*types.TierOutput, 0, len(m.Tiers))
+	for index := range m.Tiers {
+		out.Tiers = append(out.Tiers, m.Tiers[index].Out())
+	}
+	return out
+}
+
+type Plan struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Name          string             `bson:"name"`
+	Price         money.Money        `bson:"price"`
+	Prices        []money.Money      `bson:"prices"`
+	Interval      string             `bson:"interval"`
+	IntervalCount int                `bson:"interval_count"`
+	TrialPeriod   time.Duration      `bson:"trial_period"`
+	Features      []*Feature         `bson:"features"`
+	MeteredPrices []*MeteredPrice    `bson:"metered_prices"`
+	Version       int                `bson:"version"`
+	Archived      bool               `bson:"archived"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at"`
+}
+
+func (p *Plan) Out() *types.PlanOutput {
+	out := &types.PlanOutput{
+		ID:            p.ID.Hex(),
+		Name:          p.Name,
+		Price:         p.Price,
+		Interval:      p.Interval,
+		IntervalCount: p.IntervalCount,
+		TrialPeriod:   p.TrialPeriod.String(),
+		Version:       p.Version,
+		Archived:      p.Archived,
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.UpdatedAt,
+	}
+	out.Prices = make([]money.Money, 0, len(p.Prices))
+	out.Prices = append(out.Prices, p.Prices...)
+	out.Features = make([]*types.FeatureOutput, 0, len(p.Features))
+	for index := range p.Features {
+		out.Features = append(out.Features, p.Features[index].Out())
+	}
+	out.MeteredPrices = make([]*types.MeteredPriceOutput, 0, len(p.MeteredPrices))
+	for index := range p.MeteredPrices {
+		out.MeteredPrices = append(out.MeteredPrices, p.MeteredPrices[index].Out())
+	}
+	return out
+}
+
+// PlanVersion is a copy of a plan as it was at one version. Subscriptions keep
+// the plan ID and version they signed up for, so a plan update does not change
+// what they are billed.
+type PlanVersion struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	PlanID    primitive.ObjectID `bson:"plan_id"`
+	Version   int                `bson:"version"`
+	Plan      *Plan              `bson:"plan"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
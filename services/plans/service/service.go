@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/billing"
+	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/plans/models"
+	"go-subscriptions-workflow/services/plans/store"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type PlansService interface {
+	CreatePlan(ctx context.Context, in *types.CreatePlanInput) (*types.PlanOutput, error)
+	UpdatePlan(ctx context.Context, in *types.UpdatePlanInput) (*types.PlanOutput, error)
+	ArchivePlan(ctx context.Context, id string) (*types.PlanOutput, error)
+	GetPlan(ctx context.Context, id string) (*types.PlanOutput, error)
+	GetPlanVersion(ctx context.Context, id string, version int) (*types.PlanOutput, error)
+	GetPlans(ctx context.Context) ([]*types.PlanOutput, error)
+}
+
+type plansService struct {
+	plansStore store.PlansStore
+}
+
+func NewPlansService(dbConn db.Connection) PlansService {
+	return &plansService{plansStore: store.NewPlansStore(dbConn.DB())}
+}
+
+func (s *plansService) CreatePlan(ctx context.Context, in *types.CreatePlanInput) (*types.PlanOutput, error) {
+	intervalCount := newIntervalCount(in.IntervalCount)
+	err := billing.ValidateInterval(in.Interval, intervalCount)
+	if err != nil {
+		return nil, err
+	}
+	trialPeriod, err := parseTrialPeriod(in.TrialPeriod)
+	if err != nil {
+		return nil, err
+	}
+	if !in.Price.IsPositive() || in.Price.Validate() != nil {
+		return nil, fmt.Errorf("invalid plan price: %v", in.Price)
+	}
+	prices, err := newPrices(in.Prices, in.Price.Currency)
+	if err != nil {
+		return nil, err
+	}
+	meteredPrices, err := newMeteredPrices(in.MeteredPrices, in.Features, in.Price.Currency)
+	if err != nil {
+		return nil, err
+	}
+	plan := &models.Plan{
+		ID:            primitive.NewObjectID(),
+		Name:          in.Name,
+		Price:         in.Price,
+		Prices:        prices,
+		Interval:      in.Interval,
+		IntervalCount: intervalCount,
+		TrialPeriod:   trialPeriod,
+		Features:      newFeatures(in.Features),
+		MeteredPrices: meteredPrices,
+		Version:       1,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	err = s.plansStore.Create(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+	err = s.plansStore.CreateVersion(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+	return plan.Out(), nil
+}
+
+func (s *plansService) UpdatePlan(ctx context.Context, in *types.UpdatePlanInput) (*types.PlanOutput, error) {
+	id, err := primitive.ObjectIDFromHex(in.ID)
+	if err != nil {
+		return nil, err
+	}
+	intervalCount := newIntervalCount(in.IntervalCount)
+	err = billing.ValidateInterval(in.Interval, intervalCount)
+	if err != nil {
+		return nil, err
+	}
+	trialPeriod, err := parseTrialPeriod(in.TrialPeriod)
+	if err != nil {
+		return nil, err
+	}
+	if !in.Price.IsPositive() || in.Price.Validate() != nil {
+		return nil, fmt.Errorf("invalid plan price: %v", in.Price)
+	}
+	prices, err := newPrices(in.Prices, in.Price.Currency)
+	if err != nil {
+		return nil, err
+	}
+	meteredPrices, err := newMeteredPrices(in.MeteredPrices, in.Features, in.Price.Currency)
+	if err != nil {
+		return nil, err
+	}
+	plan := &models.Plan{
+		ID:            id,
+		Name:          in.Name,
+		Price:         in.Price,
+		Prices:        prices,
+		Interval:      in.Interval,
+		IntervalCount: intervalCount,
+		TrialPeriod:   trialPeriod,
+		Features:      newFeatures(in.Features),
+		MeteredPrices: meteredPrices,
+		UpdatedAt:     time.Now(),
+	}
+	plan, err = s.plansStore.Update(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+	err = s.plansStore.CreateVersion(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+	return plan.Out(), nil
+}
+
+func (s *plansService) ArchivePlan(ctx context.Context, id string) (*types.PlanOutput, error) {
+	planID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.plansStore.Archive(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	return plan.Out(), nil
+}
+
+func (s *plansService) GetPlan(ctx context.Context, id string) (*types.PlanOutput, error) {
+	planID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.plansStore.Get(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	return plan.Out(), nil
+}
+
+// GetPlanVersion returns the plan as it was at version.
+func (s *plansService) GetPlanVersion(ctx context.Context, id string, version int) (*types.PlanOutput, error) {
+	planID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.plansStore.GetVersion(ctx, planID, version)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("plan version not found: plan_id=%v, version=%d", id, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return plan.Out(), nil
+}
+
+func (s *plansService) GetPlans(ctx context.Context) ([]*types.PlanOutput, error) {
+	plans, err := s.plansStore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.PlanOutput, 0, len(plans))
+	for index := range plans {
+		out = append(out, plans[index].Out())
+	}
+	return out, nil
+}
+
+func newIntervalCount(count int) int {
+	if count == 0 {
+		return 1
+	}
+	return count
+}
+
+func parseTrialPeriod(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	trialPeriod, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if trialPeriod < 0 {
+		return 0, fmt.Errorf("invalid trial period: %s", value)
+	}
+	return trialPeriod, nil
+}
+
+func newFeatures(names []string) []*models.Feature {
+	features := make([]*models.Feature, 0, len(names))
+	for index := range names {
+		features = append(features, &models.Feature{Name: names[index]})
+	}
+	return features
+}
+
+func newPrices(in []money.Money, currency string) ([]money.Money, error) {
+	prices := make([]money.Money, 0, len(in))
+	currencies := map[string]bool{currency: true}
+	for index := range in {
+		if !in[index].IsPositive() || in[index].Validate() != nil {
+			return nil, fmt.Errorf("invalid plan price: %v", in[index])
+		}
+		if currencies[in[index].Currency] {
+			return nil, fmt.Errorf("duplicated plan price currency: currency=%v", in[index].Currency)
+		}
+		currencies[in[index].Currency] = true
+		prices = append(prices, in[index])
+	}
+	return prices, nil
+}
+
+func newMeteredPrices(in []*types.MeteredPriceInput, features []string, currency string) ([]*models.MeteredPrice, error) {
+	meteredPrices := make([]*models.MeteredPrice, 0, len(in))
+	metered := make(map[string]bool)
+	for index := range in {
+		if !contains(features, in[index].Feature) {
+			return nil, fmt.Errorf("metered feature not in plan features: feature=%v", in[index].Feature)
+		}
+		if metered[in[index].Feature] {
+			return nil, fmt.Errorf("duplicated metered feature: feature=%v", in[index].Feature)
+		}
+		metered[in[index].Feature] = true
+		meteredPrice := &models.MeteredPrice{
+			Feature:   in[index].Feature,
+			Pricing:   in[index].Pricing,
+			UnitPrice: withCurrency(in[index].UnitPrice, currency),
+			Tiers:     make([]*models.Tier, 0, len(in[index].Tiers)),
+		}
+		tiers := make([]billing.Tier, 0, len(in[index].Tiers))
+		for _, tier := range in[index].Tiers {
+			meteredTier := &models.Tier{
+				UpTo:      tier.UpTo,
+				UnitPrice: withCurrency(tier.UnitPrice, currency),
+				FlatPrice: withCurrency(tier.FlatPrice, currency),
+			}
+			meteredPrice.Tiers = append(meteredPrice.Tiers, meteredTier)
+			tiers = append(tiers, billing.Tier{UpTo: meteredTier.UpTo, UnitPrice: meteredTier.UnitPrice, FlatPrice: meteredTier.FlatPrice})
+		}
+		err := billing.ValidateMeteredPrice(meteredPrice.Pricing, meteredPrice.UnitPrice, tiers, currency)
+		if err != nil {
+			return nil, fmt.Errorf("%v: feature=%v", err, meteredPrice.Feature)
+		}
+		meteredPrices = append(meteredPrices, meteredPrice)
+	}
+	return meteredPrices, nil
+}
+
+func withCurrency(price money.Money, currency string) money.Money {
+	if price.Currency == "" && price.IsZero() {
+		return money.Zero(currency)
+	}
+	return price
+}
+
+func contains(values []string, value string) bool {
+	for index := range values {
+		if values[index] == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"go-subscriptions-workflow/services/plans/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+type PlansStore interface {
+	Create(ctx context.Context, plan *models.Plan) error
+	Update(ctx context.Context, plan *models.Plan) (*models.Plan, error)
+	Archive(ctx context.Context, id primitive.ObjectID) (*models.Plan, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.Plan, error)
+	GetAll(ctx context.Context) ([]*models.Plan, error)
+	CreateVersion(ctx context.Context, plan *models.Plan) error
+	GetVersion(ctx context.Context, id primitive.ObjectID, version int) (*models.Plan, error)
+}
+
+type plansStore struct {
+	coll         *mongo.Collection
+	versionsColl *mongo.Collection
+}
+
+func NewPlansStore(dbConn *mongo.Database) PlansStore {
+	return &plansStore{
+		coll:         dbConn.Collection("plans"),
+		versionsColl: dbConn.Collection("plan_versions"),
+	}
+}
+
+func (s *plansStore) Create(ctx context.Context, plan *models.Plan) error {
+	result, err := s.coll.InsertOne(ctx, plan)
+	if err != nil {
+		return err
+	}
+	log.Printf("plan created: %+v\n", result)
+	return nil
+}
+
+// Update saves the plan and bumps its version in the same write, so
+// concurrent updates never end up with the same version.
+func (s *plansStore) Update(ctx context.Context, plan *models.Plan) (*models.Plan, error) {
+
+	update := bson.M{
+		"$set": bson.M{
+			"name":           plan.Name,
+			"price":          plan.Price,
+			"prices":         plan.Prices,
+			"interval":       plan.Interval,
+			"interval_count": plan.IntervalCount,
+			"trial_period":   plan.TrialPeriod,
+			"features":       plan.Features,
+			"metered_prices": plan.MeteredPrices,
+			"updated_at":     plan.UpdatedAt,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	var updated models.Plan
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.coll.FindOneAndUpdate(ctx, bson.M{"_id": plan.ID}, update, opts).Decode(&updated)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("plan updated: id=%v, version=%d\n", updated.ID.Hex(), updated.Version)
+	return &updated, nil
+}
+
+func (s *plansStore) Archive(ctx context.Context, id primitive.ObjectID) (*models.Plan, error) {
+	update := bson.M{"$set": bson.M{"archived": true, "updated_at": time.Now()}}
+	var plan models.Plan
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.coll.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&plan)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("plan archived: id=%v\n", plan.ID.Hex())
+	return &plan, nil
+}
+
+func (s *plansStore) Get(ctx context.Context, id primitive.ObjectID) (*models.Plan, error) {
+	var plan models.Plan
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&plan)
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (s *plansStore) GetAll(ctx context.Context) ([]*models.Plan, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var plans []*models.Plan
+	err = cursor.All(ctx, &plans)
+	if err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+func (s *plansStore) CreateVersion(ctx context.Context, plan *models.Plan) error {
+	version := &models.PlanVersion{
+		ID:        primitive.NewObjectID(),
+		PlanID:    plan.ID,
+		Version:   plan.Version,
+		Plan:      plan,
+		CreatedAt: time.Now(),
+	}
+	result, err := s.versionsColl.InsertOne(ctx, version)
+	if err != nil {
+		return err
+	}
+	log.Printf("plan version created: %+v\n", result)
+	return nil
+}
+
+func (s *plansStore) GetVersion(ctx context.Context, id primitive.ObjectID, version int) (*models.Plan, error) {
+	var planVersion models.PlanVersion
+	err := s.versionsColl.FindOne(ctx, bson.M{"plan_id": id, "version": version}).Decode(&planVersion)
+	if err != nil {
+		return nil, err
+	}
+	return planVersion.Plan, nil
+}
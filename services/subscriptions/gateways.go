@@ -0,0 +1,20 @@
+//go:build !fakegateway
+// +build !fakegateway
+
+package main
+
+import (
+	"errors"
+	"go-subscriptions-workflow/services/payments/gateway"
+	paymentsshared "go-subscriptions-workflow/services/payments/shared"
+	"go-subscriptions-workflow/util"
+)
+
+// fakeGateways refuses to start when the fake gateway is enabled in a build
+// without it, as its payment methods could never be charged.
+func fakeGateways() []gateway.PaymentGateway {
+	if paymentsshared.FakeGatewayEnabled() {
+		util.PanicOnError(errors.New("fake payment gateway enabled but not built: use the fakegateway build tag"))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build fakegateway
+// +build fakegateway
+
+package main
+
+import (
+	"go-subscriptions-workflow/services/payments/fake"
+	"go-subscriptions-workflow/services/payments/gateway"
+	paymentsshared "go-subscriptions-workflow/services/payments/shared"
+	"log"
+)
+
+// fakeGateways registers the scripted fake gateway when it is enabled. It is
+// only built with the fakegateway tag, for development and tests.
+func fakeGateways() []gateway.PaymentGateway {
+	if !paymentsshared.FakeGatewayEnabled() {
+		return nil
+	}
+	log.Println("fake payment gateway enabled!")
+	return []gateway.PaymentGateway{fake.NewGateway(fake.DefaultScenarios())}
+}
@@ -16,6 +16,14 @@ func Register(svc service.SubscriptionsServiceServer, consumer rmq.Consumer) {
 	h := &subscriptionsHandlers{svc: svc}
 	consumer.HandleFunc(rmq.NewHandleMessageType(&types.StartSubscriptionRequest{}), h.HandleStartSubscription)
 	consumer.HandleFunc(rmq.NewHandleMessageType(&types.CancelSubscriptionRequest{}), h.HandleCancelSubscription)
+	consumer.HandleFunc(rmq.NewHandleMessageType(&types.ReactivateSubscriptionRequest{}), h.HandleReactivateSubscription)
+	consumer.HandleFunc(rmq.NewHandleMessageType(&types.ChangePlanSubscriptionRequest{}), h.HandleChangePlanSubscription)
+	consumer.HandleFunc(rmq.NewHandleMessageType(&types.ApplyCouponSubscriptionRequest{}), h.HandleApplyCouponSubscription)
+	consumer.HandleFunc(rmq.NewHandleMessageType(&types.RetryPaymentRequest{}), h.HandleRetryPayment)
+	consumer.HandleFunc(rmq.NewHandleMessageType(&types.PauseSubscriptionRequest{}), h.HandlePauseSubscription)
+	consumer.HandleFunc(rmq.NewHandleMessageType(&types.ResumeSubscriptionRequest{}), h.HandleResumeSubscription)
+	consumer.HandleFunc(rmq.NewHandleMessageType(&types.RecordUsageRequest{}), h.HandleRecordUsage)
+	consumer.HandleFunc(rmq.NewHandleMessageType(&types.RefundRequest{}), h.HandleRefund)
 }
 
 func (h *subscriptionsHandlers) HandleStartSubscription(ctx context.Context, data []byte) error {
@@ -37,3 +45,83 @@ func (h *subscriptionsHandlers) HandleCancelSubscription(ctx context.Context, da
 	_, err = h.svc.Cancel(ctx, &req)
 	return err
 }
+
+func (h *subscriptionsHandlers) HandleReactivateSubscription(ctx context.Context, data []byte) error {
+	var req types.ReactivateSubscriptionRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return err
+	}
+	_, err = h.svc.Reactivate(ctx, &req)
+	return err
+}
+
+func (h *subscriptionsHandlers) HandleChangePlanSubscription(ctx context.Context, data []byte) error {
+	var req types.ChangePlanSubscriptionRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return err
+	}
+	_, err = h.svc.ChangePlan(ctx, &req)
+	return err
+}
+
+func (h *subscriptionsHandlers) HandleApplyCouponSubscription(ctx context.Context, data []byte) error {
+	var req types.ApplyCouponSubscriptionRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return err
+	}
+	_, err = h.svc.ApplyCoupon(ctx, &req)
+	return err
+}
+
+func (h *subscriptionsHandlers) HandleRetryPayment(ctx context.Context, data []byte) error {
+	var req types.RetryPaymentRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return err
+	}
+	_, err = h.svc.RetryPayment(ctx, &req)
+	return err
+}
+
+func (h *subscriptionsHandlers) HandlePauseSubscription(ctx context.Context, data []byte) error {
+	var req types.PauseSubscriptionRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return err
+	}
+	_, err = h.svc.Pause(ctx, &req)
+	return err
+}
+
+func (h *subscriptionsHandlers) HandleResumeSubscription(ctx context.Context, data []byte) error {
+	var req types.ResumeSubscriptionRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return err
+	}
+	_, err = h.svc.Resume(ctx, &req)
+	return err
+}
+
+func (h *subscriptionsHandlers) HandleRecordUsage(ctx context.Context, data []byte) error {
+	var req types.RecordUsageRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return err
+	}
+	_, err = h.svc.RecordUsage(ctx, &req)
+	return err
+}
+
+func (h *subscriptionsHandlers) HandleRefund(ctx context.Context, data []byte) error {
+	var req types.RefundRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return err
+	}
+	_, err = h.svc.Refund(ctx, &req)
+	return err
+}
@@ -6,9 +6,19 @@ import (
 	"github.com/streadway/amqp"
 	"go-subscriptions-workflow/db"
 	"go-subscriptions-workflow/rmq"
+	couponssvc "go-subscriptions-workflow/services/coupons/service"
+	exchangeratessvc "go-subscriptions-workflow/services/exchangerates/service"
+	invoicessvc "go-subscriptions-workflow/services/invoices/service"
+	ledgersvc "go-subscriptions-workflow/services/ledger/service"
+	"go-subscriptions-workflow/services/payments/balance"
+	"go-subscriptions-workflow/services/payments/gateway"
+	paymentsshared "go-subscriptions-workflow/services/payments/shared"
+	planssvc "go-subscriptions-workflow/services/plans/service"
 	"go-subscriptions-workflow/services/subscriptions/handlers"
 	"go-subscriptions-workflow/services/subscriptions/service"
 	"go-subscriptions-workflow/services/subscriptions/shared"
+	taxessvc "go-subscriptions-workflow/services/taxes/service"
+	usagesvc "go-subscriptions-workflow/services/usage/service"
 	userssvc "go-subscriptions-workflow/services/users/service"
 	"go-subscriptions-workflow/util"
 	"go.temporal.io/sdk/client"
@@ -21,6 +31,8 @@ func init() {
 	util.PanicOnError(err)
 	db.LoadConfigFromEnv()
 	rmq.LoadConfigFromEnv()
+	shared.LoadConfigFromEnv()
+	paymentsshared.LoadConfigFromEnv()
 }
 
 func main() {
@@ -63,8 +75,23 @@ func main() {
 	defer temporalClient.Close()
 	log.Println("temporal client connected!")
 
-	usersService := userssvc.NewUsersService(dbConn)
-	subscriptionsService := service.NewSubscriptionsServiceServer(dbConn, usersService, temporalClient)
+	err = service.CheckSearchAttributes(context.Background(), temporalClient)
+	util.PanicOnError(err)
+	log.Println("search attributes registered!")
+
+	ledgerService := ledgersvc.NewLedgerService(dbConn)
+	usersService := userssvc.NewUsersService(dbConn, ledgerService)
+	plansService := planssvc.NewPlansService(dbConn)
+	couponsService := couponssvc.NewCouponsService(dbConn)
+	usageService := usagesvc.NewUsageService(dbConn)
+	exchangeRatesService := exchangeratessvc.NewExchangeRatesService(dbConn)
+	invoicesService := invoicessvc.NewInvoicesService(dbConn)
+	taxesService := taxessvc.NewTaxesService(dbConn)
+	paymentGateways := gateway.NewGateways(append(
+		[]gateway.PaymentGateway{balance.NewGateway(usersService, exchangeRatesService, ledgerService)},
+		fakeGateways()...,
+	)...)
+	subscriptionsService := service.NewSubscriptionsServiceServer(dbConn, usersService, plansService, couponsService, usageService, exchangeRatesService, invoicesService, taxesService, paymentGateways, temporalClient)
 	handlers.Register(subscriptionsService, consumer)
 
 	log.Println("subscriptions service is running...")
@@ -1,6 +1,10 @@
 package models
 
 import (
+	"go-subscriptions-workflow/billing"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/coupons/shared"
+	subsshared "go-subscriptions-workflow/services/subscriptions/shared"
 	"go-subscriptions-workflow/types"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
@@ -14,40 +18,266 @@ func (f *Feature) Out() *types.FeatureOutput {
 	return &types.FeatureOutput{Name: f.Name}
 }
 
+type Tier struct {
+	UpTo      int64       `bson:"up_to"`
+	UnitPrice money.Money `bson:"unit_price"`
+	FlatPrice money.Money `bson:"flat_price"`
+}
+
+func (t *Tier) Out() *types.TierOutput {
+	return &types.TierOutput{
+		UpTo:      t.UpTo,
+		UnitPrice: t.UnitPrice,
+		FlatPrice: t.FlatPrice,
+	}
+}
+
+type MeteredPrice struct {
+	Feature   string      `bson:"feature"`
+	Pricing   string      `bson:"pricing"`
+	UnitPrice money.Money `bson:"unit_price"`
+	Tiers     []*Tier     `bson:"tiers"`
+}
+
+func (m *MeteredPrice) Amount(quantity int64) money.Money {
+	tiers := make([]billing.Tier, 0, len(m.Tiers))
+	for index := range m.Tiers {
+		tiers = append(tiers, billing.Tier{
+			UpTo:      m.Tiers[index].UpTo,
+			UnitPrice: m.Tiers[index].UnitPrice,
+			FlatPrice: m.Tiers[index].FlatPrice,
+		})
+	}
+	return billing.MeteredAmount(m.Pricing, m.UnitPrice, tiers, quantity)
+}
+
+func (m *MeteredPrice) Out() *types.MeteredPriceOutput {
+	out := &types.MeteredPriceOutput{
+		Feature:   m.Feature,
+		Pricing:   m.Pricing,
+		UnitPrice: m.UnitPrice,
+	}
+	out.Tiers = make([]*types.TierOutput, 0, len(m.Tiers))
+	for index := range m.Tiers {
+		out.Tiers = append(out.Tiers, m.Tiers[index].Out())
+	}
+	return out
+}
+
+type Pause struct {
+	PausedAt  time.Time  `bson:"paused_at"`
+	ResumeAt  *time.Time `bson:"resume_at"`
+	ResumedAt *time.Time `bson:"resumed_at"`
+}
+
+func (p *Pause) Out() *types.PauseOutput {
+	return &types.PauseOutput{
+		PausedAt:  p.PausedAt,
+		ResumeAt:  p.ResumeAt,
+		ResumedAt: p.ResumedAt,
+	}
+}
+
+type Discount struct {
+	CouponID        primitive.ObjectID `bson:"coupon_id"`
+	Code            string             `bson:"code"`
+	Type            string             `bson:"type"`
+	PercentOff      float64            `bson:"percent_off"`
+	AmountOff       money.Money        `bson:"amount_off"`
+	Duration        string             `bson:"duration"`
+	CyclesRemaining int                `bson:"cycles_remaining"`
+	AppliedAt       time.Time          `bson:"applied_at"`
+}
+
+func (d *Discount) Amount(price money.Money) (money.Money, error) {
+	switch d.Type {
+	case shared.TypePercent:
+		return money.Min(price.Percent(d.PercentOff), price)
+	case shared.TypeFixed:
+		return money.Min(d.AmountOff, price)
+	default:
+		return money.Zero(price.Currency), nil
+	}
+}
+
+func (d *Discount) Redeem() bool {
+	if d.Duration == shared.DurationForever {
+		return true
+	}
+	d.CyclesRemaining--
+	return d.CyclesRemaining > 0
+}
+
+func (d *Discount) Out() *types.DiscountOutput {
+	return &types.DiscountOutput{
+		CouponID:        d.CouponID.Hex(),
+		Code:            d.Code,
+		Type:            d.Type,
+		PercentOff:      d.PercentOff,
+		AmountOff:       d.AmountOff,
+		Duration:        d.Duration,
+		CyclesRemaining: d.CyclesRemaining,
+		AppliedAt:       d.AppliedAt,
+	}
+}
+
+type Charge struct {
+	Amount          money.Money `bson:"amount"`
+	Usage           money.Money `bson:"usage"`
+	Discount        money.Money `bson:"discount"`
+	Tax             money.Money `bson:"tax"`
+	Paid            money.Money `bson:"paid"`
+	CouponCode      string      `bson:"coupon_code"`
+	Gateway         string      `bson:"gateway"`
+	PaymentMethodID string      `bson:"payment_method_id"`
+	PaymentID       string      `bson:"payment_id"`
+	ChargedAt       time.Time   `bson:"charged_at"`
+}
+
+func (c *Charge) Out() *types.ChargeOutput {
+	return &types.ChargeOutput{
+		Amount:          c.Amount,
+		Usage:           c.Usage,
+		Discount:        c.Discount,
+		Tax:             c.Tax,
+		Paid:            c.Paid,
+		CouponCode:      c.CouponCode,
+		Gateway:         c.Gateway,
+		PaymentMethodID: c.PaymentMethodID,
+		PaymentID:       c.PaymentID,
+		ChargedAt:       c.ChargedAt,
+	}
+}
+
 type Subscription struct {
-	ID          primitive.ObjectID `bson:"_id"`
-	UserID      primitive.ObjectID `bson:"user_id"`
-	Price       float64            `bson:"price"`
-	Features    []*Feature         `bson:"features"`
-	Activations int                `bson:"activations"`
-	ActivatedAt time.Time          `bson:"activated_at"`
-	ExpiresAt   time.Time          `bson:"expires_at"`
-	Canceled    bool               `bson:"canceled"`
-	CanceledAt  *time.Time         `bson:"canceled_at"`
-	Disabled    bool               `bson:"disabled"`
-	DisabledAt  *time.Time         `bson:"disabled_at"`
-	CreatedAt   time.Time          `bson:"created_at"`
-	UpdatedAt   time.Time          `bson:"updated_at"`
+	ID                 primitive.ObjectID  `bson:"_id"`
+	UserID             primitive.ObjectID  `bson:"user_id"`
+	PlanID             primitive.ObjectID  `bson:"plan_id"`
+	PlanVersion        int                 `bson:"plan_version"`
+	PendingPlanID      *primitive.ObjectID `bson:"pending_plan_id"`
+	PendingPlanVersion int                 `bson:"pending_plan_version"`
+	Status             string              `bson:"status"`
+	Price              money.Money         `bson:"price"`
+	Interval           string              `bson:"interval"`
+	IntervalCount      int                 `bson:"interval_count"`
+	BillingAnchor      int                 `bson:"billing_anchor"`
+	Features           []*Feature          `bson:"features"`
+	MeteredPrices      []*MeteredPrice     `bson:"metered_prices"`
+	Activations        int                 `bson:"activations"`
+	TrialEndsAt        *time.Time          `bson:"trial_ends_at"`
+	PastDueSince       *time.Time          `bson:"past_due_since"`
+	GraceEndsAt        *time.Time          `bson:"grace_ends_at"`
+	PausedAt           *time.Time          `bson:"paused_at"`
+	ResumeAt           *time.Time          `bson:"resume_at"`
+	Pauses             []*Pause            `bson:"pauses"`
+	Discount           *Discount           `bson:"discount"`
+	LastCharge         *Charge             `bson:"last_charge"`
+	ActivatedAt        time.Time           `bson:"activated_at"`
+	ExpiresAt          time.Time           `bson:"expires_at"`
+	Canceled           bool                `bson:"canceled"`
+	CanceledAt         *time.Time          `bson:"canceled_at"`
+	CancelAtPeriodEnd  bool                `bson:"cancel_at_period_end"`
+	Disabled           bool                `bson:"disabled"`
+	DisabledAt         *time.Time          `bson:"disabled_at"`
+	CreatedAt          time.Time           `bson:"created_at"`
+	UpdatedAt          time.Time           `bson:"updated_at"`
+}
+
+// NextPeriodEnd returns the end of the period after the current one.
+// Subscriptions started before plans had intervals repeat their first period.
+func (s *Subscription) NextPeriodEnd() (time.Time, error) {
+	if s.Interval == "" {
+		return s.ExpiresAt.Add(s.ExpiresAt.Sub(s.ActivatedAt)), nil
+	}
+	return billing.NextPeriodEnd(s.ExpiresAt, s.Interval, s.IntervalCount, s.BillingAnchor)
+}
+
+func (s *Subscription) MeteredPrice(feature string) *MeteredPrice {
+	for index := range s.MeteredPrices {
+		if s.MeteredPrices[index].Feature == feature {
+			return s.MeteredPrices[index]
+		}
+	}
+	return nil
+}
+
+func (s *Subscription) GrantsAccess(t time.Time) bool {
+	switch {
+	case s.Canceled, s.Disabled:
+		return false
+	case s.Status == subsshared.StatusPaused:
+		return false
+	case s.Status == subsshared.StatusPastDue:
+		return s.GraceEndsAt != nil && s.GraceEndsAt.After(t)
+	default:
+		return s.ExpiresAt.After(t)
+	}
+}
+
+func (s *Subscription) AccessEndsAt() *time.Time {
+	switch {
+	case s.Canceled:
+		return s.CanceledAt
+	case s.Disabled:
+		return s.DisabledAt
+	case s.CancelAtPeriodEnd:
+		expiresAt := s.ExpiresAt
+		return &expiresAt
+	default:
+		return nil
+	}
 }
 
 func (s *Subscription) Out() *types.SubscriptionOutput {
 	out := &types.SubscriptionOutput{
-		ID:          s.ID.Hex(),
-		UserID:      s.UserID.Hex(),
-		Price:       s.Price,
-		Activations: s.Activations,
-		ActivatedAt: s.ActivatedAt,
-		ExpiresAt:   s.ExpiresAt,
-		Canceled:    s.Canceled,
-		CanceledAt:  s.CanceledAt,
-		Disabled:    s.Disabled,
-		DisabledAt:  s.DisabledAt,
-		CreatedAt:   s.CreatedAt,
-		UpdatedAt:   s.UpdatedAt,
+		ID:                s.ID.Hex(),
+		UserID:            s.UserID.Hex(),
+		PlanID:            s.PlanID.Hex(),
+		PlanVersion:       s.PlanVersion,
+		Status:            s.Status,
+		Price:             s.Price,
+		Interval:          s.Interval,
+		IntervalCount:     s.IntervalCount,
+		BillingAnchor:     s.BillingAnchor,
+		Activations:       s.Activations,
+		TrialEndsAt:       s.TrialEndsAt,
+		PastDueSince:      s.PastDueSince,
+		GraceEndsAt:       s.GraceEndsAt,
+		PausedAt:          s.PausedAt,
+		ResumeAt:          s.ResumeAt,
+		ActivatedAt:       s.ActivatedAt,
+		ExpiresAt:         s.ExpiresAt,
+		Canceled:          s.Canceled,
+		CanceledAt:        s.CanceledAt,
+		CancelAtPeriodEnd: s.CancelAtPeriodEnd,
+		AccessEndsAt:      s.AccessEndsAt(),
+		Disabled:          s.Disabled,
+		DisabledAt:        s.DisabledAt,
+		CreatedAt:         s.CreatedAt,
+		UpdatedAt:         s.UpdatedAt,
+	}
+	if s.PendingPlanID != nil {
+		out.PendingPlanID = s.PendingPlanID.Hex()
+		out.PendingPlanVersion = s.PendingPlanVersion
+	}
+	if s.Discount != nil {
+		out.Discount = s.Discount.Out()
+	}
+	if s.LastCharge != nil {
+		out.LastCharge = s.LastCharge.Out()
+	}
+	out.Pauses = make([]*types.PauseOutput, 0, len(s.Pauses))
+	for index := range s.Pauses {
+		out.Pauses = append(out.Pauses, s.Pauses[index].Out())
 	}
 	out.Features = make([]*types.FeatureOutput, 0, len(s.Features))
 	for index := range s.Features {
 		out.Features = append(out.Features, s.Features[index].Out())
 	}
+	out.MeteredPrices = make([]*types.MeteredPriceOutput, 0, len(s.MeteredPrices))
+	for index := range s.MeteredPrices {
+		out.MeteredPrices = append(out.MeteredPrices, s.MeteredPrices[index].Out())
+	}
+	out.Usage = make([]*types.UsageOutput, 0)
 	return out
 }
@@ -2,19 +2,61 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"go-subscriptions-workflow/types"
+	"time"
+
+	"go.temporal.io/sdk/activity"
 )
 
+// Activities runs the steps of SubscriptionsWorkflow against svc. Tests mock
+// them by name with the SDK's test environment, so a zero value works there.
 type Activities struct {
 	svc SubscriptionsServiceServer
 }
 
+func NewActivities(svc SubscriptionsServiceServer) *Activities {
+	return &Activities{svc: svc}
+}
+
 func (a *Activities) Charge(ctx context.Context, state SubscriptionState) (SubscriptionState, error) {
 	out, err := a.svc.Charge(ctx, &types.ChargeSubscriptionRequest{ID: state.ID})
 	if err != nil {
 		return state, HandleError(err)
 	}
-	return NewState(out), nil
+	return a.newState(ctx, out)
+}
+
+func (a *Activities) Debit(ctx context.Context, state SubscriptionState, attempt int) (types.SubscriptionDebitOutput, error) {
+	out, err := a.svc.Debit(ctx, &types.DebitSubscriptionRequest{ID: state.ID, Attempt: attempt})
+	if err != nil {
+		return types.SubscriptionDebitOutput{}, HandleError(err)
+	}
+	return *out, nil
+}
+
+func (a *Activities) RecordActivation(ctx context.Context, state SubscriptionState, debit types.SubscriptionDebitOutput) (SubscriptionState, error) {
+	out, err := a.svc.RecordActivation(ctx, &types.RecordActivationRequest{Debit: &debit})
+	if err != nil {
+		return state, HandleError(err)
+	}
+	return a.newState(ctx, out)
+}
+
+func (a *Activities) RefundDebit(ctx context.Context, state SubscriptionState, debit types.SubscriptionDebitOutput) error {
+	_, err := a.svc.RefundDebit(ctx, &types.RefundDebitRequest{Debit: &debit})
+	if err != nil {
+		return HandleError(err)
+	}
+	return nil
+}
+
+func (a *Activities) RenderReceipt(ctx context.Context, state SubscriptionState) error {
+	_, err := a.svc.RenderReceipt(ctx, &types.RenderReceiptRequest{ID: state.ID, Activation: state.Activations})
+	if err != nil {
+		return HandleError(err)
+	}
+	return nil
 }
 
 func (a *Activities) Disable(ctx context.Context, state SubscriptionState) (SubscriptionState, error) {
@@ -22,6 +64,87 @@ func (a *Activities) Disable(ctx context.Context, state SubscriptionState) (Subs
 	if err != nil {
 		return state, HandleError(err)
 	}
-	return NewState(out), nil
+	return a.newState(ctx, out)
 }
 
+func (a *Activities) ApplyPlanChange(ctx context.Context, state SubscriptionState, req types.ApplyPlanChangeRequest) (SubscriptionState, error) {
+	req.ID = state.ID
+	out, err := a.svc.ApplyPlanChange(ctx, &req)
+	if err != nil {
+		return state, HandleError(err)
+	}
+	return a.newState(ctx, out)
+}
+
+func (a *Activities) RedeemCoupon(ctx context.Context, state SubscriptionState, code string) (SubscriptionState, error) {
+	out, err := a.svc.RedeemCoupon(ctx, &types.RedeemCouponRequest{ID: state.ID, Code: code})
+	if err != nil {
+		return state, HandleError(err)
+	}
+	return a.newState(ctx, out)
+}
+
+func (a *Activities) MarkPastDue(ctx context.Context, state SubscriptionState, graceEndsAt, pastDueSince time.Time) (SubscriptionState, error) {
+	req := &types.MarkPastDueSubscriptionRequest{ID: state.ID, GraceEndsAt: graceEndsAt, PastDueSince: pastDueSince}
+	out, err := a.svc.MarkPastDue(ctx, req)
+	if err != nil {
+		return state, HandleError(err)
+	}
+	return a.newState(ctx, out)
+}
+
+func (a *Activities) MarkPaused(ctx context.Context, state SubscriptionState, resumeAt *time.Time, pausedAt time.Time) (SubscriptionState, error) {
+	out, err := a.svc.MarkPaused(ctx, &types.MarkPausedSubscriptionRequest{ID: state.ID, ResumeAt: resumeAt, PausedAt: pausedAt})
+	if err != nil {
+		return state, HandleError(err)
+	}
+	return a.newState(ctx, out)
+}
+
+func (a *Activities) MarkResumed(ctx context.Context, state SubscriptionState, resumedAt time.Time) (SubscriptionState, error) {
+	out, err := a.svc.MarkResumed(ctx, &types.MarkResumedSubscriptionRequest{ID: state.ID, ResumedAt: resumedAt})
+	if err != nil {
+		return state, HandleError(err)
+	}
+	return a.newState(ctx, out)
+}
+
+func (a *Activities) SetCancelAtPeriodEnd(ctx context.Context, state SubscriptionState, cancelAtPeriodEnd bool) (SubscriptionState, error) {
+	req := &types.SetCancelAtPeriodEndRequest{ID: state.ID, CancelAtPeriodEnd: cancelAtPeriodEnd}
+	out, err := a.svc.SetCancelAtPeriodEnd(ctx, req)
+	if err != nil {
+		return state, HandleError(err)
+	}
+	return a.newState(ctx, out)
+}
+
+func (a *Activities) FinalizeCancel(ctx context.Context, state SubscriptionState) (SubscriptionState, error) {
+	out, err := a.svc.FinalizeCancel(ctx, &types.FinalizeCancelSubscriptionRequest{ID: state.ID})
+	if err != nil {
+		return state, HandleError(err)
+	}
+	return a.newState(ctx, out)
+}
+
+// Refund applies a refund signaled to the workflow. Signals sent before refunds
+// carried an ID are keyed by the activity, which stays the same across retries.
+func (a *Activities) Refund(ctx context.Context, state SubscriptionState, req types.RefundRequest) (SubscriptionState, error) {
+	if req.RefundID == "" {
+		info := activity.GetInfo(ctx)
+		req.RefundID = fmt.Sprintf("%s:%s", info.WorkflowExecution.RunID, info.ActivityID)
+	}
+	out, err := a.svc.ApplyRefund(ctx, &req)
+	if err != nil {
+		return state, HandleError(err)
+	}
+	return a.newState(ctx, out)
+}
+
+func (a *Activities) newState(ctx context.Context, out *types.SubscriptionOutput) (SubscriptionState, error) {
+	usage, err := a.svc.GetUsage(ctx, &types.GetUsageRequest{ID: out.ID, Activation: out.Activations})
+	if err != nil {
+		return NewState(out), HandleError(err)
+	}
+	out.Usage = usage
+	return NewState(out), nil
+}
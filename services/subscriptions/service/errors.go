@@ -1,17 +1,44 @@
 package service
 
 import (
+	"errors"
+	couponsshared "go-subscriptions-workflow/services/coupons/shared"
+	paymentsshared "go-subscriptions-workflow/services/payments/shared"
 	"go-subscriptions-workflow/services/subscriptions/shared"
 	"go.temporal.io/sdk/temporal"
 )
 
-var ErrInsufficientFunds = temporal.NewNonRetryableApplicationError(shared.ErrInsufficientFunds.Error(), "user_poor", shared.ErrInsufficientFunds, nil)
+// Application error types the activities fail with. The workflow matches on
+// these, since only the type survives the activity error.
+const (
+	ErrorTypeInsufficientFunds = "user_poor"
+	ErrorTypeInvalidCoupon     = "invalid_coupon"
+	ErrorTypePaymentLost       = "payment_lost"
+)
+
+var ErrInsufficientFunds = temporal.NewNonRetryableApplicationError(shared.ErrInsufficientFunds.Error(), ErrorTypeInsufficientFunds, shared.ErrInsufficientFunds, nil)
+
+// ErrDebitRefunded ends a charge whose activation could not be recorded once its
+// debit is refunded. Nothing was declined, so the subscription does not go past
+// due; the charge fails as it would on any other error.
+var ErrDebitRefunded = errors.New("debit refunded")
 
 func HandleError(err error) error {
 	switch err.Error() {
 	case shared.ErrInsufficientFunds.Error():
 		return ErrInsufficientFunds
+	case couponsshared.ErrCouponExpired.Error(), couponsshared.ErrCouponExhausted.Error():
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrorTypeInvalidCoupon, err, nil)
+	case paymentsshared.ErrPaymentLost.Error():
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrorTypePaymentLost, err, nil)
 	default:
 		return err
 	}
 }
+
+// IsDeclined reports whether err is a charge the user's payment methods
+// declined, which sends the subscription to dunning.
+func IsDeclined(err error) bool {
+	var applicationErr *temporal.ApplicationError
+	return errors.As(err, &applicationErr) && applicationErr.Type() == ErrorTypeInsufficientFunds
+}
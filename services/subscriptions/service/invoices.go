@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/money"
+	invoicesshared "go-subscriptions-workflow/services/invoices/shared"
+	"go-subscriptions-workflow/services/subscriptions/models"
+	"go-subscriptions-workflow/services/subscriptions/shared"
+	"go-subscriptions-workflow/types"
+	"log"
+	"time"
+)
+
+// newCharge prices the next period of the subscription: the plan price plus the
+// metered usage, less the discount. It returns the invoice lines along with it.
+func newCharge(subscription *models.Subscription, usage []*types.InvoiceLineInput) (*models.Charge, []*types.InvoiceLineInput, error) {
+	lines := []*types.InvoiceLineInput{planLine(subscription, subscription.Price)}
+	usageAmount := money.Zero(subscription.Price.Currency)
+	var err error
+	for _, line := range usage {
+		usageAmount, err = usageAmount.Add(line.Amount)
+		if err != nil {
+			return nil, nil, err
+		}
+		lines = append(lines, line)
+	}
+	amount, err := subscription.Price.Add(usageAmount)
+	if err != nil {
+		return nil, nil, err
+	}
+	charge := &models.Charge{
+		Amount:    amount,
+		Usage:     usageAmount,
+		Discount:  money.Zero(amount.Currency),
+		Tax:       money.Zero(amount.Currency),
+		ChargedAt: time.Now(),
+	}
+	if subscription.Discount != nil {
+		charge.Discount, err = subscription.Discount.Amount(charge.Amount)
+		if err != nil {
+			return nil, nil, err
+		}
+		charge.Amount, err = charge.Amount.Sub(charge.Discount)
+		if err != nil {
+			return nil, nil, err
+		}
+		charge.CouponCode = subscription.Discount.Code
+		lines = append(lines, &types.InvoiceLineInput{
+			Type:        invoicesshared.LineTypeDiscount,
+			Description: fmt.Sprintf("Coupon %s", subscription.Discount.Code),
+			Quantity:    1,
+			Amount:      charge.Discount.Neg(),
+		})
+	}
+	return charge, lines, nil
+}
+
+// chargeModel restores a charge priced and paid by Debit.
+func chargeModel(charge *types.ChargeOutput) *models.Charge {
+	return &models.Charge{
+		Amount:          charge.Amount,
+		Usage:           charge.Usage,
+		Discount:        charge.Discount,
+		Tax:             charge.Tax,
+		Paid:            charge.Paid,
+		CouponCode:      charge.CouponCode,
+		Gateway:         charge.Gateway,
+		PaymentMethodID: charge.PaymentMethodID,
+		PaymentID:       charge.PaymentID,
+		ChargedAt:       charge.ChargedAt,
+	}
+}
+
+// taxCharge taxes the discounted amount of the charge. An exclusive tax is added
+// to the amount to debit; an inclusive one is only shown on the invoice.
+func (s *subscriptionsService) taxCharge(ctx context.Context, user *types.UserOutput, charge *models.Charge, lines []*types.InvoiceLineInput) ([]*types.InvoiceLineInput, error) {
+	tax, err := s.tax(ctx, user, charge.Amount)
+	if err != nil {
+		return nil, err
+	}
+	charge.Tax = tax.Tax
+	charge.Amount = tax.Gross
+	if tax.Tax.IsPositive() {
+		lines = append(lines, taxLine(tax))
+	}
+	return lines, nil
+}
+
+func (s *subscriptionsService) tax(ctx context.Context, user *types.UserOutput, amount money.Money) (*types.TaxOutput, error) {
+	in := &types.CalculateTaxInput{TaxExempt: user.TaxExempt, Amount: amount}
+	if user.BillingAddress != nil {
+		in.Country = user.BillingAddress.Country
+		in.Region = user.BillingAddress.Region
+	}
+	return s.taxesService.Calculate(ctx, in)
+}
+
+func taxLine(tax *types.TaxOutput) *types.InvoiceLineInput {
+	description := fmt.Sprintf("%s %v%%", tax.Name, tax.Percent)
+	if tax.Inclusive {
+		description += " (included)"
+	}
+	return &types.InvoiceLineInput{
+		Type:        invoicesshared.LineTypeTax,
+		Description: description,
+		Quantity:    1,
+		Amount:      tax.Tax,
+		Inclusive:   tax.Inclusive,
+	}
+}
+
+func planLine(subscription *models.Subscription, amount money.Money) *types.InvoiceLineInput {
+	description := fmt.Sprintf("Plan %s v%d", subscription.PlanID.Hex(), subscription.PlanVersion)
+	if subscription.Status == shared.StatusTrialing {
+		description += " (trial)"
+	}
+	return &types.InvoiceLineInput{
+		Type:        invoicesshared.LineTypePlan,
+		Description: description,
+		Quantity:    1,
+		Amount:      amount,
+	}
+}
+
+// usageLines bills the usage recorded in the current activation, converted into
+// the subscription currency. Trials are not billed for usage.
+func (s *subscriptionsService) usageLines(ctx context.Context, subscription *models.Subscription) ([]*types.InvoiceLineInput, error) {
+	if subscription.Status == shared.StatusTrialing || len(subscription.MeteredPrices) == 0 {
+		return nil, nil
+	}
+	usage, err := s.usageService.GetUsage(ctx, subscription.ID.Hex(), subscription.Activations)
+	if err != nil {
+		return nil, err
+	}
+	var lines []*types.InvoiceLineInput
+	for index := range usage {
+		meteredPrice := subscription.MeteredPrice(usage[index].Feature)
+		if meteredPrice == nil {
+			continue
+		}
+		amount, err := s.exchangeRatesService.Convert(ctx, meteredPrice.Amount(usage[index].Quantity), subscription.Price.Currency)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, &types.InvoiceLineInput{
+			Type:        invoicesshared.LineTypeUsage,
+			Description: fmt.Sprintf("Usage of %s", usage[index].Feature),
+			Quantity:    usage[index].Quantity,
+			Amount:      amount,
+		})
+	}
+	return lines, nil
+}
+
+// issueInvoice records the invoice of money that has already moved, so a failure
+// here is logged rather than failing (and retrying) the charge itself.
+func (s *subscriptionsService) issueInvoice(ctx context.Context, in *types.CreateInvoiceInput) {
+	invoice, err := s.invoicesService.CreateInvoice(ctx, in)
+	if err != nil {
+		log.Printf("invoice failed: subscription_id=%v, ledger_reference=%v, error=%v\n",
+			in.SubscriptionID, in.LedgerReference, err)
+		return
+	}
+	log.Printf("invoice issued: subscription_id=%v, number=%d, total=%v\n",
+		invoice.SubscriptionID, invoice.Number, invoice.Total)
+}
+
+// writeOff records the period a past due subscription never paid for as an
+// uncollectible invoice.
+func (s *subscriptionsService) writeOff(ctx context.Context, subscription *models.Subscription) error {
+	usage, err := s.usageLines(ctx, subscription)
+	if err != nil {
+		return err
+	}
+	charge, lines, err := newCharge(subscription, usage)
+	if err != nil {
+		return err
+	}
+	user, err := s.usersService.GetUser(ctx, subscription.UserID.Hex())
+	if err != nil {
+		return err
+	}
+	lines, err = s.taxCharge(ctx, user, charge, lines)
+	if err != nil {
+		return err
+	}
+	periodEnd, err := subscription.NextPeriodEnd()
+	if err != nil {
+		return err
+	}
+	s.issueInvoice(ctx, &types.CreateInvoiceInput{
+		UserID:          subscription.UserID.Hex(),
+		SubscriptionID:  subscription.ID.Hex(),
+		Status:          invoicesshared.StatusUncollectible,
+		Lines:           lines,
+		Paid:            money.Zero(charge.Amount.Currency),
+		PeriodStart:     subscription.ExpiresAt,
+		PeriodEnd:       periodEnd,
+		LedgerReference: referenceID(subscription, subscription.Activations+1),
+	})
+	return nil
+}
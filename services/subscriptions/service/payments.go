@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-subscriptions-workflow/money"
+	paymentsshared "go-subscriptions-workflow/services/payments/shared"
+	"go-subscriptions-workflow/services/subscriptions/models"
+	"go-subscriptions-workflow/services/subscriptions/shared"
+	"go-subscriptions-workflow/types"
+	"log"
+	"time"
+)
+
+// paymentMethods returns the methods to charge the user with, in priority
+// order, skipping those whose gateway is not enabled. Users without usable
+// stored methods pay from their internal balance.
+func paymentMethods(user *types.UserOutput) []*types.PaymentMethodOutput {
+	methods := make([]*types.PaymentMethodOutput, 0, len(user.PaymentMethods))
+	for _, method := range user.PaymentMethods {
+		if !paymentsshared.GatewayEnabled(method.Gateway) {
+			log.Printf("payment method skipped: user_id=%v, payment_method_id=%v, gateway=%v\n",
+				user.ID, method.ID, method.Gateway)
+			continue
+		}
+		methods = append(methods, method)
+	}
+	if len(methods) == 0 {
+		return []*types.PaymentMethodOutput{{Gateway: paymentsshared.GatewayBalance}}
+	}
+	return methods
+}
+
+func (s *subscriptionsService) authorize(ctx context.Context, user *types.UserOutput, method *types.PaymentMethodOutput, amount money.Money, idempotencyKey string) (*types.PaymentOutput, error) {
+	paymentGateway, err := s.paymentGateways.Get(method.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	in := &types.AuthorizePaymentInput{
+		IdempotencyKey: idempotencyKey,
+		UserID:         user.ID,
+		Source:         method.Token,
+		Amount:         amount,
+	}
+	authorization, err := paymentGateway.Authorize(ctx, in)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+	return authorization, nil
+}
+
+func (s *subscriptionsService) capture(ctx context.Context, user *types.UserOutput, method *types.PaymentMethodOutput, amount money.Money, idempotencyKey string) (*types.PaymentOutput, error) {
+	authorization, err := s.authorize(ctx, user, method, amount, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	paymentGateway, err := s.paymentGateways.Get(authorization.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	in := &types.CapturePaymentInput{
+		IdempotencyKey: idempotencyKey,
+		PaymentID:      authorization.ID,
+		UserID:         user.ID,
+		Amount:         authorization.Amount,
+	}
+	payment, err := paymentGateway.Capture(ctx, in)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+	return payment, nil
+}
+
+// pay tries the user's payment methods in priority order until one captures
+// amount, and returns the payment with the method that paid. Each method gets
+// its own idempotency key derived from the charge's, so a retried activity gets
+// back the payment it already captured.
+func (s *subscriptionsService) pay(ctx context.Context, user *types.UserOutput, amount money.Money, idempotencyKey string) (*types.PaymentOutput, *types.PaymentMethodOutput, error) {
+	methods := paymentMethods(user)
+	if !amount.IsPositive() {
+		return &types.PaymentOutput{
+			Gateway:   methods[0].Gateway,
+			Status:    paymentsshared.StatusCaptured,
+			Amount:    amount,
+			CreatedAt: time.Now(),
+		}, methods[0], nil
+	}
+	for _, method := range methods {
+		key := idempotencyKey
+		if method.ID != "" {
+			key = idempotencyKey + ":" + method.ID
+		}
+		payment, err := s.capture(ctx, user, method, amount, key)
+		if err == shared.ErrInsufficientFunds {
+			log.Printf("payment method failed: user_id=%v, payment_method_id=%v, gateway=%v\n",
+				user.ID, method.ID, method.Gateway)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return payment, method, nil
+	}
+	return nil, nil, shared.ErrInsufficientFunds
+}
+
+// refundPayment returns amount of a payment to the user who made it. Payments
+// made before payment gateways were made from the balance.
+func (s *subscriptionsService) refundPayment(ctx context.Context, userID, gateway, paymentID string, amount money.Money, idempotencyKey string) (*types.PaymentOutput, error) {
+	name := gateway
+	if name == "" {
+		name = paymentsshared.GatewayBalance
+	}
+	paymentGateway, err := s.paymentGateways.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	in := &types.RefundPaymentInput{
+		IdempotencyKey: idempotencyKey,
+		PaymentID:      paymentID,
+		UserID:         userID,
+		Amount:         amount,
+	}
+	return paymentGateway.Refund(ctx, in)
+}
+
+// paymentError turns a declined payment into ErrInsufficientFunds, which moves
+// the subscription to past due instead of retrying the activity.
+func paymentError(err error) error {
+	if errors.Is(err, paymentsshared.ErrPaymentDeclined) || errors.Is(err, paymentsshared.ErrInsufficientFunds) {
+		log.Printf("payment declined: %v\n", err)
+		return shared.ErrInsufficientFunds
+	}
+	return err
+}
+
+func setPayment(charge *models.Charge, payment *types.PaymentOutput, method *types.PaymentMethodOutput) {
+	charge.Paid = payment.Amount
+	charge.Gateway = payment.Gateway
+	charge.PaymentMethodID = method.ID
+	charge.PaymentID = payment.ID
+}
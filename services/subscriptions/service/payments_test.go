@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/payments/gateway"
+	paymentsshared "go-subscriptions-workflow/services/payments/shared"
+	"go-subscriptions-workflow/types"
+	"testing"
+	"time"
+)
+
+// recordingGateway approves every payment and records the sources it was
+// asked to authorize.
+type recordingGateway struct {
+	name       string
+	authorized []string
+}
+
+func (g *recordingGateway) Name() string {
+	return g.name
+}
+
+func (g *recordingGateway) Authorize(ctx context.Context, in *types.AuthorizePaymentInput) (*types.PaymentOutput, error) {
+	g.authorized = append(g.authorized, in.Source)
+	return g.payment(paymentsshared.StatusAuthorized, in.Amount), nil
+}
+
+func (g *recordingGateway) Capture(ctx context.Context, in *types.CapturePaymentInput) (*types.PaymentOutput, error) {
+	return g.payment(paymentsshared.StatusCaptured, in.Amount), nil
+}
+
+func (g *recordingGateway) Refund(ctx context.Context, in *types.RefundPaymentInput) (*types.PaymentOutput, error) {
+	return g.payment(paymentsshared.StatusRefunded, in.Amount), nil
+}
+
+func (g *recordingGateway) payment(status string, amount money.Money) *types.PaymentOutput {
+	return &types.PaymentOutput{ID: g.name + "-payment", Gateway: g.name, Status: status, Amount: amount, CreatedAt: time.Now()}
+}
+
+func TestPaySkipsDisabledGateways(t *testing.T) {
+	if paymentsshared.GatewayEnabled(paymentsshared.GatewayFake) {
+		t.Fatal("fake gateway enabled by default")
+	}
+	fake := &recordingGateway{name: paymentsshared.GatewayFake}
+	balance := &recordingGateway{name: paymentsshared.GatewayBalance}
+	s := &subscriptionsService{paymentGateways: gateway.NewGateways(fake, balance)}
+
+	tests := []struct {
+		name    string
+		methods []*types.PaymentMethodOutput
+		source  string
+	}{
+		{
+			name: "falls back past disabled method",
+			methods: []*types.PaymentMethodOutput{
+				{ID: "method-fake", Gateway: paymentsshared.GatewayFake, Token: "tok_fake", Priority: 0},
+				{ID: "method-balance", Gateway: paymentsshared.GatewayBalance, Token: "tok_balance", Priority: 1},
+			},
+			source: "tok_balance",
+		},
+		{
+			name: "only disabled methods pay from balance",
+			methods: []*types.PaymentMethodOutput{
+				{ID: "method-fake", Gateway: paymentsshared.GatewayFake, Token: "tok_fake", Priority: 0},
+			},
+			source: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.authorized = nil
+			balance.authorized = nil
+			user := &types.UserOutput{ID: "61800a0fe1b2c3d4e5f60717", PaymentMethods: tt.methods}
+
+			payment, method, err := s.pay(context.Background(), user, money.New(1000, money.DefaultCurrency), "reference:1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fake.authorized) != 0 {
+				t.Errorf("disabled gateway authorized %v", fake.authorized)
+			}
+			if payment.Gateway != paymentsshared.GatewayBalance || method.Gateway != paymentsshared.GatewayBalance {
+				t.Errorf("paid with gateway=%v, method gateway=%v, want %v", payment.Gateway, method.Gateway, paymentsshared.GatewayBalance)
+			}
+			if len(balance.authorized) != 1 || balance.authorized[0] != tt.source {
+				t.Errorf("balance authorized %v, want [%q]", balance.authorized, tt.source)
+			}
+		})
+	}
+}
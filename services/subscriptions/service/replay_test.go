@@ -0,0 +1,88 @@
+package service_test
+
+import (
+	"fmt"
+	"github.com/gogo/protobuf/jsonpb"
+	"go-subscriptions-workflow/services/subscriptions/service"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/worker"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testLogger sends the replayer's log to the test output.
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) log(level, msg string, keyvals ...interface{}) {
+	l.t.Helper()
+	l.t.Logf("%s %s %s", level, msg, fmt.Sprint(keyvals...))
+}
+
+func (l testLogger) Debug(msg string, keyvals ...interface{}) { l.log("DEBUG", msg, keyvals...) }
+func (l testLogger) Info(msg string, keyvals ...interface{})  { l.log("INFO", msg, keyvals...) }
+func (l testLogger) Warn(msg string, keyvals ...interface{})  { l.log("WARN", msg, keyvals...) }
+func (l testLogger) Error(msg string, keyvals ...interface{}) { l.log("ERROR", msg, keyvals...) }
+
+// TestReplayHistories replays the histories exported from runs of older
+// workflow versions against the current code. A failure means a change to
+// SubscriptionsWorkflow needs a workflow.GetVersion branch.
+//
+// The baseline histories come from runs of the workflow before any change was
+// versioned. Those that completed are cut after their last scheduled activity,
+// as the replayer compares the encoded result, which gains the state's new
+// fields.
+func TestReplayHistories(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "subscription_*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no workflow histories in testdata")
+	}
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			// The replayer shares the worker's workflow cache, which would
+			// otherwise resume a run replayed earlier in the process.
+			decodeInput(t, file)
+			worker.PurgeStickyWorkflowCache()
+			replayer := worker.NewWorkflowReplayer()
+			replayer.RegisterWorkflow(service.SubscriptionsWorkflow)
+			err := replayer.ReplayWorkflowHistoryFromJSONFile(testLogger{t}, file)
+			if err != nil {
+				t.Fatalf("replay %s: %v", file, err)
+			}
+		})
+	}
+}
+
+// decodeInput decodes the state a history was started with. The replayer does
+// not report a workflow that fails on its input, so a state field whose type
+// changed would otherwise replay without an error.
+func decodeInput(t *testing.T, file string) {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var history historypb.History
+	err = jsonpb.Unmarshal(f, &history)
+	if err != nil {
+		t.Fatalf("read %s: %v", file, err)
+	}
+	input := history.Events[0].GetWorkflowExecutionStartedEventAttributes().GetInput()
+	var state service.SubscriptionState
+	var activities *service.Activities
+	err = converter.GetDefaultDataConverter().FromPayloads(input, &state, &activities)
+	if err != nil {
+		t.Fatalf("decode input of %s: %v", file, err)
+	}
+	if state.ID == "" || state.Price.Currency == "" {
+		t.Fatalf("decode input of %s: incomplete state %+v", file, state)
+	}
+}
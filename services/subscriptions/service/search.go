@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/types"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Search attributes SubscriptionsWorkflow indexes its state with. They have to
+// be registered on the cluster first, see docker/temporal/search-attributes.sh.
+const (
+	SearchAttributeUserID    = "UserID"
+	SearchAttributeStatus    = "Status"
+	SearchAttributePlanID    = "PlanID"
+	SearchAttributeExpiresAt = "ExpiresAt"
+	SearchAttributeCanceled  = "Canceled"
+)
+
+var searchAttributeTypes = map[string]enumspb.IndexedValueType{
+	SearchAttributeUserID:    enumspb.INDEXED_VALUE_TYPE_KEYWORD,
+	SearchAttributeStatus:    enumspb.INDEXED_VALUE_TYPE_KEYWORD,
+	SearchAttributePlanID:    enumspb.INDEXED_VALUE_TYPE_KEYWORD,
+	SearchAttributeExpiresAt: enumspb.INDEXED_VALUE_TYPE_DATETIME,
+	SearchAttributeCanceled:  enumspb.INDEXED_VALUE_TYPE_BOOL,
+}
+
+// CheckSearchAttributes fails when one of the search attributes is missing on
+// the cluster or registered with another type.
+func CheckSearchAttributes(ctx context.Context, temporalClient client.Client) error {
+	res, err := temporalClient.GetSearchAttributes(ctx)
+	if err != nil {
+		return err
+	}
+	missing := make([]string, 0)
+	for name, valueType := range searchAttributeTypes {
+		if res.GetKeys()[name] != valueType {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("search attributes not registered: names=%v", strings.Join(missing, ","))
+	}
+	return nil
+}
+
+func (s SubscriptionState) SearchAttributes() map[string]interface{} {
+	return map[string]interface{}{
+		SearchAttributeUserID:    s.UserID,
+		SearchAttributeStatus:    s.Status,
+		SearchAttributePlanID:    s.PlanID,
+		SearchAttributeExpiresAt: s.ExpiresAt.UTC(),
+		SearchAttributeCanceled:  s.Canceled,
+	}
+}
+
+// changedSearchAttributes returns the search attributes of state that differ
+// from the ones already indexed.
+func changedSearchAttributes(state SubscriptionState, indexed *commonpb.SearchAttributes) map[string]interface{} {
+	attributes := state.SearchAttributes()
+	dataConverter := converter.GetDefaultDataConverter()
+	for name, value := range attributes {
+		payload, err := dataConverter.ToPayload(value)
+		if err == nil && bytes.Equal(payload.GetData(), indexed.GetIndexedFields()[name].GetData()) {
+			delete(attributes, name)
+		}
+	}
+	return attributes
+}
+
+// searchQuery builds the visibility query for req. The request is validated,
+// so its values need no escaping.
+func searchQuery(req *types.SearchSubscriptionWorkflowsRequest) string {
+	conditions := []string{"WorkflowType = 'SubscriptionsWorkflow'"}
+	if req.UserID != "" {
+		conditions = append(conditions, fmt.Sprintf("%s = '%s'", SearchAttributeUserID, req.UserID))
+	}
+	if req.Status != "" {
+		conditions = append(conditions, fmt.Sprintf("%s = '%s'", SearchAttributeStatus, req.Status))
+	}
+	if req.PlanID != "" {
+		conditions = append(conditions, fmt.Sprintf("%s = '%s'", SearchAttributePlanID, req.PlanID))
+	}
+	if req.ExpiresAfter != nil {
+		conditions = append(conditions, fmt.Sprintf("%s >= '%s'", SearchAttributeExpiresAt, req.ExpiresAfter.UTC().Format(time.RFC3339Nano)))
+	}
+	if req.ExpiresBefore != nil {
+		conditions = append(conditions, fmt.Sprintf("%s < '%s'", SearchAttributeExpiresAt, req.ExpiresBefore.UTC().Format(time.RFC3339Nano)))
+	}
+	if req.Canceled != nil {
+		conditions = append(conditions, fmt.Sprintf("%s = %t", SearchAttributeCanceled, *req.Canceled))
+	}
+	if req.Running {
+		conditions = append(conditions, "ExecutionStatus = 'Running'")
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func workflowOut(info *workflowpb.WorkflowExecutionInfo) (*types.SubscriptionWorkflowOutput, error) {
+	out := &types.SubscriptionWorkflowOutput{
+		WorkflowID: info.GetExecution().GetWorkflowId(),
+		RunID:      info.GetExecution().GetRunId(),
+		Execution:  info.GetStatus().String(),
+		StartedAt:  info.GetStartTime(),
+		ClosedAt:   info.GetCloseTime(),
+	}
+	fields := info.GetSearchAttributes().GetIndexedFields()
+	values := map[string]interface{}{
+		SearchAttributeUserID:    &out.UserID,
+		SearchAttributeStatus:    &out.Status,
+		SearchAttributePlanID:    &out.PlanID,
+		SearchAttributeExpiresAt: &out.ExpiresAt,
+		SearchAttributeCanceled:  &out.Canceled,
+	}
+	dataConverter := converter.GetDefaultDataConverter()
+	for name, valuePtr := range values {
+		err := dataConverter.FromPayload(fields[name], valuePtr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -3,13 +3,26 @@ package service
 import (
 	"context"
 	"fmt"
+	"go-subscriptions-workflow/billing"
 	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/money"
+	couponssvc "go-subscriptions-workflow/services/coupons/service"
+	couponsshared "go-subscriptions-workflow/services/coupons/shared"
+	exchangeratessvc "go-subscriptions-workflow/services/exchangerates/service"
+	invoicessvc "go-subscriptions-workflow/services/invoices/service"
+	invoicesshared "go-subscriptions-workflow/services/invoices/shared"
+	"go-subscriptions-workflow/services/payments/gateway"
+	paymentsshared "go-subscriptions-workflow/services/payments/shared"
+	planssvc "go-subscriptions-workflow/services/plans/service"
 	"go-subscriptions-workflow/services/subscriptions/models"
 	"go-subscriptions-workflow/services/subscriptions/shared"
 	"go-subscriptions-workflow/services/subscriptions/store"
+	taxessvc "go-subscriptions-workflow/services/taxes/service"
+	usagesvc "go-subscriptions-workflow/services/usage/service"
 	userssvc "go-subscriptions-workflow/services/users/service"
 	"go-subscriptions-workflow/types"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.temporal.io/api/workflowservice/v1"
 	"go.temporal.io/sdk/client"
 	"log"
 	"time"
@@ -18,21 +31,50 @@ import (
 type SubscriptionsClient interface {
 	GetSubscriptions(ctx context.Context) ([]*types.SubscriptionOutput, error)
 	GetSubscription(ctx context.Context, req *types.GetSubscriptionRequest) (*types.SubscriptionOutput, error)
+	SearchWorkflows(ctx context.Context, req *types.SearchSubscriptionWorkflowsRequest) (*types.SubscriptionWorkflowsOutput, error)
 }
 
 type SubscriptionsServiceServer interface {
 	Start(ctx context.Context, req *types.StartSubscriptionRequest) (*types.SubscriptionOutput, error)
 	Charge(ctx context.Context, req *types.ChargeSubscriptionRequest) (*types.SubscriptionOutput, error)
+	Debit(ctx context.Context, req *types.DebitSubscriptionRequest) (*types.SubscriptionDebitOutput, error)
+	RecordActivation(ctx context.Context, req *types.RecordActivationRequest) (*types.SubscriptionOutput, error)
+	RefundDebit(ctx context.Context, req *types.RefundDebitRequest) (*types.PaymentOutput, error)
+	RenderReceipt(ctx context.Context, req *types.RenderReceiptRequest) (*types.ReceiptOutput, error)
 	Cancel(ctx context.Context, req *types.CancelSubscriptionRequest) (*types.SubscriptionOutput, error)
+	Reactivate(ctx context.Context, req *types.ReactivateSubscriptionRequest) (*types.SubscriptionOutput, error)
+	SetCancelAtPeriodEnd(ctx context.Context, req *types.SetCancelAtPeriodEndRequest) (*types.SubscriptionOutput, error)
+	FinalizeCancel(ctx context.Context, req *types.FinalizeCancelSubscriptionRequest) (*types.SubscriptionOutput, error)
 	Disable(ctx context.Context, req *types.DisableSubscriptionRequest) (*types.SubscriptionOutput, error)
+	Refund(ctx context.Context, req *types.RefundRequest) (*types.SubscriptionOutput, error)
+	ApplyRefund(ctx context.Context, req *types.RefundRequest) (*types.SubscriptionOutput, error)
+	ChangePlan(ctx context.Context, req *types.ChangePlanSubscriptionRequest) (*types.SubscriptionOutput, error)
+	ApplyPlanChange(ctx context.Context, req *types.ApplyPlanChangeRequest) (*types.SubscriptionOutput, error)
+	ApplyCoupon(ctx context.Context, req *types.ApplyCouponSubscriptionRequest) (*types.SubscriptionOutput, error)
+	RedeemCoupon(ctx context.Context, req *types.RedeemCouponRequest) (*types.SubscriptionOutput, error)
+	MarkPastDue(ctx context.Context, req *types.MarkPastDueSubscriptionRequest) (*types.SubscriptionOutput, error)
+	RetryPayment(ctx context.Context, req *types.RetryPaymentRequest) ([]*types.SubscriptionOutput, error)
+	Pause(ctx context.Context, req *types.PauseSubscriptionRequest) (*types.SubscriptionOutput, error)
+	Resume(ctx context.Context, req *types.ResumeSubscriptionRequest) (*types.SubscriptionOutput, error)
+	MarkPaused(ctx context.Context, req *types.MarkPausedSubscriptionRequest) (*types.SubscriptionOutput, error)
+	MarkResumed(ctx context.Context, req *types.MarkResumedSubscriptionRequest) (*types.SubscriptionOutput, error)
+	RecordUsage(ctx context.Context, req *types.RecordUsageRequest) (*types.UsageRecordOutput, error)
+	GetUsage(ctx context.Context, req *types.GetUsageRequest) ([]*types.UsageOutput, error)
 	GetSubscriptions(ctx context.Context) ([]*types.SubscriptionOutput, error)
 	GetSubscription(ctx context.Context, req *types.GetSubscriptionRequest) (*types.SubscriptionOutput, error)
 }
 
 type subscriptionsService struct {
-	usersService       userssvc.UsersService
-	subscriptionsStore store.SubscriptionsStore
-	temporalClient     client.Client
+	usersService         userssvc.UsersService
+	plansService         planssvc.PlansService
+	couponsService       couponssvc.CouponsService
+	usageService         usagesvc.UsageService
+	exchangeRatesService exchangeratessvc.ExchangeRatesService
+	invoicesService      invoicessvc.InvoicesService
+	taxesService         taxessvc.TaxesService
+	paymentGateways      gateway.Gateways
+	subscriptionsStore   store.SubscriptionsStore
+	temporalClient       client.Client
 }
 
 func NewSubscriptionsClient(dbConn db.Connection, usersService userssvc.UsersService, temporalClient client.Client) SubscriptionsClient {
@@ -43,11 +85,18 @@ func NewSubscriptionsClient(dbConn db.Connection, usersService userssvc.UsersSer
 	}
 }
 
-func NewSubscriptionsServiceServer(dbConn db.Connection, usersService userssvc.UsersService, temporalClient client.Client) SubscriptionsServiceServer {
+func NewSubscriptionsServiceServer(dbConn db.Connection, usersService userssvc.UsersService, plansService planssvc.PlansService, couponsService couponssvc.CouponsService, usageService usagesvc.UsageService, exchangeRatesService exchangeratessvc.ExchangeRatesService, invoicesService invoicessvc.InvoicesService, taxesService taxessvc.TaxesService, paymentGateways gateway.Gateways, temporalClient client.Client) SubscriptionsServiceServer {
 	return &subscriptionsService{
-		usersService:       usersService,
-		subscriptionsStore: store.NewSubscriptionsStore(dbConn.DB()),
-		temporalClient:     temporalClient,
+		usersService:         usersService,
+		plansService:         plansService,
+		couponsService:       couponsService,
+		usageService:         usageService,
+		exchangeRatesService: exchangeRatesService,
+		invoicesService:      invoicesService,
+		taxesService:         taxesService,
+		paymentGateways:      paymentGateways,
+		subscriptionsStore:   store.NewSubscriptionsStore(dbConn.DB()),
+		temporalClient:       temporalClient,
 	}
 }
 
@@ -56,8 +105,22 @@ func (s *subscriptionsService) Start(ctx context.Context, req *types.StartSubscr
 	if err != nil {
 		return nil, err
 	}
-	if user.Balance < shared.DefaultPrice {
-		return nil, fmt.Errorf("insufficient funds to subscribe: user_id=%v, balance=%f, price=%f", user.ID, user.Balance, shared.DefaultPrice)
+	plan, err := s.plansService.GetPlan(ctx, req.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Archived {
+		return nil, fmt.Errorf("plan is archived: plan_id=%v", plan.ID)
+	}
+	trialPeriod, err := time.ParseDuration(plan.TrialPeriod)
+	if err != nil {
+		return nil, err
+	}
+	if req.Trial && trialPeriod <= 0 {
+		return nil, fmt.Errorf("plan has no trial period: plan_id=%v", plan.ID)
+	}
+	if req.Trial && contains(user.TrialPlanIDs, plan.ID) {
+		return nil, fmt.Errorf("trial already used: user_id=%v, plan_id=%v", user.ID, plan.ID)
 	}
 	userID, _ := primitive.ObjectIDFromHex(user.ID)
 	subscriptions, err := s.subscriptionsStore.GetByUserID(ctx, userID)
@@ -69,45 +132,130 @@ func (s *subscriptionsService) Start(ctx context.Context, req *types.StartSubscr
 			return nil, fmt.Errorf("subscription already started: subscription_id=%v, user_id=%v",
 				subscriptions[index].ID, subscriptions[index].UserID)
 		}
+		// Trials started before they were recorded on the user.
+		if req.Trial && subscriptions[index].TrialEndsAt != nil && subscriptions[index].PlanID.Hex() == plan.ID {
+			return nil, fmt.Errorf("trial already used: user_id=%v, plan_id=%v", user.ID, plan.ID)
+		}
 	}
 
 	id := primitive.NewObjectID()
 
 	subscription := &models.Subscription{
-		ID:     id,
-		UserID: userID,
-		Price:  shared.DefaultPrice,
-		Features: []*models.Feature{
-			{
-				Name: "downloads",
-			},
-			{
-				Name: "uploads",
-			},
-		},
+		ID:          id,
+		UserID:      userID,
+		Status:      shared.StatusActive,
 		Activations: 1,
 		ActivatedAt: time.Now(),
-		ExpiresAt:   time.Now().Add(shared.DefaultExpiration),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
+	err = applyPlan(subscription, plan, user.Currency)
+	if err != nil {
+		return nil, err
+	}
+	subscription.BillingAnchor = subscription.ActivatedAt.Day()
+	subscription.ExpiresAt, err = billing.NextPeriodEnd(subscription.ActivatedAt, subscription.Interval,
+		subscription.IntervalCount, subscription.BillingAnchor)
+	if err != nil {
+		return nil, err
+	}
+
+	var coupon *types.CouponOutput
+	if req.CouponCode != "" {
+		coupon, err = s.couponsService.GetCouponByCode(ctx, req.CouponCode)
+		if err != nil {
+			return nil, err
+		}
+		err = checkCouponCurrency(coupon, subscription)
+		if err != nil {
+			return nil, err
+		}
+		subscription.Discount = newDiscount(coupon)
+	}
+
+	if req.Trial {
+		subscription.Status = shared.StatusTrialing
+		subscription.Activations = 0
+		subscription.ExpiresAt = subscription.ActivatedAt.Add(trialPeriod)
+		trialEndsAt := subscription.ExpiresAt
+		subscription.BillingAnchor = trialEndsAt.Day()
+		subscription.TrialEndsAt = &trialEndsAt
+	}
+
+	// Start moves no money: the first charge runs when the first period or the
+	// trial ends. Users paying from their balance must cover the price now.
+	ledgerReference := referenceID(subscription, subscription.Activations)
+	if !req.Trial {
+		charge, lines, err := newCharge(subscription, nil)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.taxCharge(ctx, user, charge, lines)
+		if err != nil {
+			return nil, err
+		}
+		if methods := paymentMethods(user); len(methods) == 1 && methods[0].Gateway == paymentsshared.GatewayBalance {
+			_, err = s.authorize(ctx, user, methods[0], charge.Amount, ledgerReference)
+			if err == shared.ErrInsufficientFunds {
+				return nil, fmt.Errorf("insufficient funds to subscribe: user_id=%v, price=%v", user.ID, charge.Amount)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// The redemption is keyed by the subscription so a retried start cannot
+	// count twice, and it is released when the subscription is not created.
+	var redemption *types.CouponRedemptionInput
+	if coupon != nil {
+		redemption = &types.CouponRedemptionInput{Code: coupon.Code, SubscriptionID: id.Hex()}
+		_, err = s.couponsService.RedeemCoupon(ctx, redemption)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Trial {
+		_, err = s.usersService.UseTrial(ctx, user.ID, plan.ID)
+		if err != nil {
+			s.releaseCoupon(ctx, redemption)
+			return nil, err
+		}
+	}
+
 	err = s.subscriptionsStore.Create(ctx, subscription)
 	if err != nil {
+		if req.Trial {
+			s.releaseTrial(ctx, user.ID, plan.ID)
+		}
+		s.releaseCoupon(ctx, redemption)
 		return nil, err
 	}
 
+	s.issueInvoice(ctx, &types.CreateInvoiceInput{
+		UserID:          subscription.UserID.Hex(),
+		SubscriptionID:  subscription.ID.Hex(),
+		Status:          invoicesshared.StatusPaid,
+		Lines:           []*types.InvoiceLineInput{planLine(subscription, money.Zero(subscription.Price.Currency))},
+		Paid:            money.Zero(subscription.Price.Currency),
+		PeriodStart:     subscription.ActivatedAt,
+		PeriodEnd:       subscription.ExpiresAt,
+		LedgerReference: ledgerReference,
+	})
+
 	out := subscription.Out()
 
 	state := NewState(out)
 
 	options := client.StartWorkflowOptions{
-		ID:                 state.ID,
-		TaskQueue:          TaskQueueName,
-		WorkflowRunTimeout: time.Hour * 24 * 30 * 6,
+		ID:               state.ID,
+		TaskQueue:        TaskQueueName,
+		SearchAttributes: state.SearchAttributes(),
 	}
 
-	we, err := s.temporalClient.ExecuteWorkflow(ctx, options, SubscriptionsWorkflow, state, &Activities{s})
+	we, err := s.temporalClient.ExecuteWorkflow(ctx, options, SubscriptionsWorkflow, state, NewActivities(s))
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +265,21 @@ func (s *subscriptionsService) Start(ctx context.Context, req *types.StartSubscr
 	return out, nil
 }
 
+// Charge debits the next activation of the subscription and records it in one
+// step. Workflows started before debits and activations were separate
+// activities still run it.
 func (s *subscriptionsService) Charge(ctx context.Context, req *types.ChargeSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	debit, err := s.Debit(ctx, &types.DebitSubscriptionRequest{ID: req.ID})
+	if err != nil {
+		return nil, err
+	}
+	return s.RecordActivation(ctx, &types.RecordActivationRequest{Debit: debit})
+}
+
+// Debit prices the next activation of the subscription and pays for it without
+// recording it. Each attempt pays under its own idempotency key, so a debit
+// refunded by RefundDebit is not found again by the next attempt.
+func (s *subscriptionsService) Debit(ctx context.Context, req *types.DebitSubscriptionRequest) (*types.SubscriptionDebitOutput, error) {
 	id, err := primitive.ObjectIDFromHex(req.ID)
 	if err != nil {
 		return nil, err
@@ -126,26 +288,88 @@ func (s *subscriptionsService) Charge(ctx context.Context, req *types.ChargeSubs
 	if err != nil {
 		return nil, err
 	}
+	usage, err := s.usageLines(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+	err = s.applyPendingPlan(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
 	user, err := s.usersService.GetUser(ctx, subscription.UserID.Hex())
 	if err != nil {
 		return nil, err
 	}
-	if user.Balance < subscription.Price {
-		return nil, shared.ErrInsufficientFunds
+
+	charge, lines, err := newCharge(subscription, usage)
+	if err != nil {
+		return nil, err
+	}
+	lines, err = s.taxCharge(ctx, user, charge, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	activation := subscription.Activations + 1
+	ledgerReference := referenceID(subscription, activation)
+	idempotencyKey := ledgerReference
+	if req.Attempt > 0 {
+		idempotencyKey = fmt.Sprintf("%s:attempt:%d", ledgerReference, req.Attempt)
+	}
+	payment, method, err := s.pay(ctx, user, charge.Amount, idempotencyKey)
+	if err != nil {
+		return nil, err
 	}
+	setPayment(charge, payment, method)
 
-	debit := new(types.DebitInput)
-	debit.Amount = subscription.Price
-	debit.UserID = subscription.UserID.Hex()
+	log.Printf("subscription debited: subscription_id=%v, activation=%d, paid=%v\n",
+		subscription.ID.Hex(), activation, charge.Paid)
 
-	_, err = s.usersService.Debit(ctx, debit)
+	return &types.SubscriptionDebitOutput{
+		ID:              subscription.ID.Hex(),
+		UserID:          subscription.UserID.Hex(),
+		Activation:      activation,
+		LedgerReference: ledgerReference,
+		Charge:          charge.Out(),
+		Lines:           lines,
+	}, nil
+}
+
+// RecordActivation extends the subscription by the period a debit paid for and
+// issues its invoice. An activation already recorded is not recorded again.
+func (s *subscriptionsService) RecordActivation(ctx context.Context, req *types.RecordActivationRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.Debit.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Activations >= req.Debit.Activation {
+		return subscription.Out(), nil
+	}
+	err = s.applyPendingPlan(ctx, subscription)
 	if err != nil {
 		return nil, err
 	}
 
-	subscription.Activations++
-	subscription.ActivatedAt = time.Now()
-	subscription.ExpiresAt = time.Now().Add(shared.DefaultExpiration)
+	if subscription.Discount != nil && !subscription.Discount.Redeem() {
+		subscription.Discount = nil
+	}
+
+	charge := chargeModel(req.Debit.Charge)
+	subscription.LastCharge = charge
+	subscription.Status = shared.StatusActive
+	subscription.PastDueSince = nil
+	subscription.GraceEndsAt = nil
+	subscription.Activations = req.Debit.Activation
+	periodEnd, err := subscription.NextPeriodEnd()
+	if err != nil {
+		return nil, err
+	}
+	subscription.ActivatedAt = subscription.ExpiresAt
+	subscription.ExpiresAt = periodEnd
 	subscription.UpdatedAt = time.Now()
 
 	err = s.subscriptionsStore.Update(ctx, subscription)
@@ -155,9 +379,93 @@ func (s *subscriptionsService) Charge(ctx context.Context, req *types.ChargeSubs
 
 	log.Println("subscription charged: ", subscription.ID.Hex())
 
+	s.issueInvoice(ctx, &types.CreateInvoiceInput{
+		UserID:          subscription.UserID.Hex(),
+		SubscriptionID:  subscription.ID.Hex(),
+		Status:          invoicesshared.StatusPaid,
+		Lines:           req.Debit.Lines,
+		Paid:            charge.Paid,
+		PeriodStart:     subscription.ActivatedAt,
+		PeriodEnd:       subscription.ExpiresAt,
+		LedgerReference: req.Debit.LedgerReference,
+		Gateway:         charge.Gateway,
+		PaymentID:       charge.PaymentID,
+	})
+
 	return subscription.Out(), nil
 }
 
+// RefundDebit compensates a debit whose activation could not be recorded. A
+// debit that was recorded after all is kept.
+func (s *subscriptionsService) RefundDebit(ctx context.Context, req *types.RefundDebitRequest) (*types.PaymentOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.Debit.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	charge := req.Debit.Charge
+	if subscription.Activations >= req.Debit.Activation && subscription.LastCharge != nil &&
+		subscription.LastCharge.PaymentID == charge.PaymentID {
+		log.Printf("subscription debit recorded, not refunded: subscription_id=%v, activation=%d\n",
+			req.Debit.ID, req.Debit.Activation)
+		return nil, nil
+	}
+	if !charge.Paid.IsPositive() {
+		return nil, nil
+	}
+
+	key := fmt.Sprintf("%s:compensation:%s", req.Debit.LedgerReference, charge.PaymentID)
+	payment, err := s.refundPayment(ctx, req.Debit.UserID, charge.Gateway, charge.PaymentID, charge.Paid, key)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("subscription debit refunded: subscription_id=%v, activation=%d, refunded=%v\n",
+		req.Debit.ID, req.Debit.Activation, payment.Amount)
+
+	return payment, nil
+}
+
+// RenderReceipt renders the receipt of the invoice issued when the
+// subscription was charged for the given activation.
+func (s *subscriptionsService) RenderReceipt(ctx context.Context, req *types.RenderReceiptRequest) (*types.ReceiptOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := s.invoicesService.GetInvoiceByReference(ctx, referenceID(subscription, req.Activation))
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.usersService.GetUser(ctx, invoice.UserID)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.plansService.GetPlanVersion(ctx, subscription.PlanID.Hex(), subscription.PlanVersion)
+	if err != nil {
+		return nil, err
+	}
+	in := &types.CreateReceiptInput{
+		InvoiceID: invoice.ID,
+		Email:     user.Email,
+		Plan:      fmt.Sprintf("%s v%d", plan.Name, subscription.PlanVersion),
+		Balance:   money.Zero(invoice.Paid.Currency),
+	}
+	for index := range user.Balances {
+		if user.Balances[index].Currency == invoice.Paid.Currency {
+			in.Balance = user.Balances[index]
+		}
+	}
+	return s.invoicesService.CreateReceipt(ctx, in)
+}
+
 func (s *subscriptionsService) Cancel(ctx context.Context, req *types.CancelSubscriptionRequest) (*types.SubscriptionOutput, error) {
 	id, err := primitive.ObjectIDFromHex(req.ID)
 	if err != nil {
@@ -175,7 +483,25 @@ func (s *subscriptionsService) Cancel(ctx context.Context, req *types.CancelSubs
 		return nil, nil
 	}
 
+	if req.AtPeriodEnd {
+		if subscription.Status != shared.StatusActive && subscription.Status != shared.StatusTrialing {
+			return nil, fmt.Errorf("subscription cannot be canceled at period end: subscription_id=%v, status=%v",
+				req.ID, subscription.Status)
+		}
+		if subscription.CancelAtPeriodEnd {
+			return subscription.Out(), nil
+		}
+		err = s.temporalClient.SignalWorkflow(ctx, req.ID, "", SignalCancelAtPeriodEnd, true)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("subscription cancel at period end requested: ", req.ID)
+		return subscription.Out(), nil
+	}
+
+	subscription.Status = shared.StatusCanceled
 	subscription.Canceled = true
+	subscription.CancelAtPeriodEnd = false
 	canceledAt := time.Now()
 	subscription.CanceledAt = &canceledAt
 	subscription.UpdatedAt = time.Now()
@@ -195,6 +521,90 @@ func (s *subscriptionsService) Cancel(ctx context.Context, req *types.CancelSubs
 	return subscription.Out(), err
 }
 
+func (s *subscriptionsService) Reactivate(ctx context.Context, req *types.ReactivateSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.UserID.Hex() != req.UserID {
+		return nil, fmt.Errorf("invalid user to reactivate subscription: user_id=%v, subscription_id=%v",
+			req.UserID, req.ID)
+	}
+	if subscription.Canceled || subscription.Disabled || !subscription.CancelAtPeriodEnd {
+		return nil, fmt.Errorf("subscription is not scheduled to cancel: subscription_id=%v", req.ID)
+	}
+	if !subscription.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("subscription period already ended: subscription_id=%v", req.ID)
+	}
+
+	err = s.temporalClient.SignalWorkflow(ctx, req.ID, "", SignalCancelAtPeriodEnd, false)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("subscription reactivation requested: ", req.ID)
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) SetCancelAtPeriodEnd(ctx context.Context, req *types.SetCancelAtPeriodEndRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.CancelAtPeriodEnd = req.CancelAtPeriodEnd
+	subscription.UpdatedAt = time.Now()
+
+	err = s.subscriptionsStore.Update(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("subscription cancel at period end updated: subscription_id=%v, cancel_at_period_end=%v\n",
+		subscription.ID.Hex(), subscription.CancelAtPeriodEnd)
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) FinalizeCancel(ctx context.Context, req *types.FinalizeCancelSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Canceled {
+		return subscription.Out(), nil
+	}
+
+	subscription.Status = shared.StatusCanceled
+	subscription.Canceled = true
+	subscription.CancelAtPeriodEnd = false
+	canceledAt := subscription.ExpiresAt
+	subscription.CanceledAt = &canceledAt
+	subscription.UpdatedAt = time.Now()
+
+	err = s.subscriptionsStore.Update(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("subscription canceled at period end: ", subscription.ID.Hex())
+
+	return subscription.Out(), nil
+}
+
 func (s *subscriptionsService) Disable(ctx context.Context, req *types.DisableSubscriptionRequest) (*types.SubscriptionOutput, error) {
 	id, err := primitive.ObjectIDFromHex(req.ID)
 	if err != nil {
@@ -205,6 +615,14 @@ func (s *subscriptionsService) Disable(ctx context.Context, req *types.DisableSu
 		return nil, err
 	}
 
+	if subscription.Status == shared.StatusPastDue {
+		err = s.writeOff(ctx, subscription)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	subscription.Status = shared.StatusDisabled
 	subscription.Disabled = true
 	disabledAt := time.Now()
 	subscription.DisabledAt = &disabledAt
@@ -220,27 +638,731 @@ func (s *subscriptionsService) Disable(ctx context.Context, req *types.DisableSu
 	return subscription.Out(), err
 }
 
-func (s *subscriptionsService) GetSubscriptions(ctx context.Context) ([]*types.SubscriptionOutput, error) {
-	subscriptions, err := s.subscriptionsStore.GetAll(ctx)
+// Refund refunds a paid invoice. A refund that also cancels an active
+// subscription runs inside its workflow; any other refund is applied here.
+func (s *subscriptionsService) Refund(ctx context.Context, req *types.RefundRequest) (*types.SubscriptionOutput, error) {
+	if req.RefundID == "" {
+		req.RefundID = primitive.NewObjectID().Hex()
+	}
+	invoice, err := s.invoicesService.GetInvoiceByID(ctx, req.InvoiceID)
 	if err != nil {
 		return nil, err
 	}
-	out := make([]*types.SubscriptionOutput, 0, len(subscriptions))
-	for index := range subscriptions {
-		out = append(out, subscriptions[index].Out())
+	id, err := primitive.ObjectIDFromHex(invoice.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	live := !subscription.Canceled && !subscription.Disabled
+
+	if req.Cancel && live && (subscription.Status == shared.StatusActive || subscription.Status == shared.StatusTrialing) {
+		signal := RefundSignal{
+			RefundID:  req.RefundID,
+			InvoiceID: req.InvoiceID,
+			Amount:    req.Amount,
+			Reason:    req.Reason,
+			Cancel:    req.Cancel,
+		}
+		err = s.temporalClient.SignalWorkflow(ctx, subscription.ID.Hex(), "", SignalRefund, signal)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("subscription refund requested: ", subscription.ID.Hex())
+		return subscription.Out(), nil
+	}
+
+	out, err := s.ApplyRefund(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Cancel && live {
+		err = s.temporalClient.SignalWorkflow(ctx, out.ID, "", SignalCancelSubscription, true)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return out, nil
 }
 
-func (s *subscriptionsService) GetSubscription(ctx context.Context, req *types.GetSubscriptionRequest) (*types.SubscriptionOutput, error) {
-	res, err := s.temporalClient.QueryWorkflow(ctx, req.ID, "", QuerySubscriptionState, nil)
+func (s *subscriptionsService) ApplyRefund(ctx context.Context, req *types.RefundRequest) (*types.SubscriptionOutput, error) {
+	if req.RefundID == "" {
+		return nil, fmt.Errorf("refund has no refund id: invoice_id=%v", req.InvoiceID)
+	}
+	invoice, err := s.invoicesService.GetInvoiceByID(ctx, req.InvoiceID)
 	if err != nil {
 		return nil, err
 	}
-	var state SubscriptionState
-	err = res.Get(&state)
+	id, err := primitive.ObjectIDFromHex(invoice.SubscriptionID)
 	if err != nil {
 		return nil, err
 	}
-	return state.Out(), nil
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !invoice.Total.IsPositive() {
+		return nil, invoicesshared.ErrInvoiceNotRefundable
+	}
+
+	amount, err := invoice.Total.Sub(invoice.Refunded)
+	if err != nil {
+		return nil, err
+	}
+	if req.Amount != nil {
+		amount = *req.Amount
+	}
+	refunded := invoice.Paid.Ratio(float64(amount.Amount) / float64(invoice.Total.Amount))
+
+	// The credit note is keyed by the refund, so a retry finds the one it
+	// created and refunds under the same key instead of refunding again. It
+	// stays pending, and is not counted on the invoice, until the money is back.
+	in := &types.CreateCreditNoteInput{
+		InvoiceID: invoice.ID,
+		RefundID:  req.RefundID,
+		Amount:    amount,
+		Refunded:  refunded,
+		Reason:    req.Reason,
+	}
+	creditNote, err := s.invoicesService.CreateCreditNote(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	if creditNote.Status == invoicesshared.CreditNoteStatusPending {
+		if creditNote.Refunded.IsPositive() {
+			key := fmt.Sprintf("%s:refund:%s", invoice.LedgerReference, creditNote.RefundID)
+			_, err = s.refundPayment(ctx, invoice.UserID, invoice.Gateway, invoice.PaymentID, creditNote.Refunded, key)
+			if err != nil {
+				return nil, err
+			}
+		}
+		creditNote, err = s.invoicesService.CompleteCreditNote(ctx, creditNote.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("subscription refunded: subscription_id=%v, invoice_id=%v, credit_note=%d, refunded=%v\n",
+		subscription.ID.Hex(), invoice.ID, creditNote.Number, creditNote.Refunded)
+
+	if req.Cancel && !subscription.Canceled && !subscription.Disabled {
+		subscription.Status = shared.StatusCanceled
+		subscription.Canceled = true
+		subscription.CancelAtPeriodEnd = false
+		canceledAt := time.Now()
+		subscription.CanceledAt = &canceledAt
+		subscription.UpdatedAt = time.Now()
+
+		err = s.subscriptionsStore.Update(ctx, subscription)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Println("subscription canceled: ", subscription.ID.Hex())
+	}
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) ChangePlan(ctx context.Context, req *types.ChangePlanSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.UserID.Hex() != req.UserID {
+		return nil, fmt.Errorf("invalid user to change subscription plan: user_id=%v, subscription_id=%v",
+			req.UserID, req.ID)
+	}
+	if subscription.Canceled || subscription.Disabled {
+		return nil, fmt.Errorf("subscription is not active: subscription_id=%v", req.ID)
+	}
+	plan, err := s.plansService.GetPlan(ctx, req.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Archived {
+		return nil, fmt.Errorf("plan is archived: plan_id=%v", plan.ID)
+	}
+	if plan.ID == subscription.PlanID.Hex() && plan.Version == subscription.PlanVersion {
+		return nil, fmt.Errorf("subscription already on plan: subscription_id=%v, plan_id=%v", req.ID, plan.ID)
+	}
+	price := planPrice(plan, subscription.Price.Currency)
+	if price.Currency != subscription.Price.Currency {
+		return nil, fmt.Errorf("plan has no price in subscription currency: subscription_id=%v, plan_id=%v, currency=%v",
+			req.ID, plan.ID, subscription.Price.Currency)
+	}
+
+	signal := ChangePlanSignal{
+		PlanID:      plan.ID,
+		PlanVersion: plan.Version,
+		Price:       price,
+	}
+
+	err = s.temporalClient.SignalWorkflow(ctx, req.ID, "", SignalChangePlan, signal)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("subscription plan change requested: subscription_id=%v, plan_id=%v\n", req.ID, plan.ID)
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) ApplyPlanChange(ctx context.Context, req *types.ApplyPlanChangeRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.plansService.GetPlanVersion(ctx, req.PlanID, req.PlanVersion)
+	if err != nil {
+		return nil, err
+	}
+	// Downgrades wait for the period end instead of crediting the difference.
+	if req.Proration.IsNegative() {
+		return nil, fmt.Errorf("negative plan change proration: subscription_id=%v, proration=%v", req.ID, req.Proration)
+	}
+
+	ledgerReference := fmt.Sprintf("%s:plan:%s:v%d", referenceID(subscription, subscription.Activations),
+		plan.ID, plan.Version)
+	paid := money.Zero(req.Proration.Currency)
+	var payment *types.PaymentOutput
+	lines := []*types.InvoiceLineInput{{
+		Type:        invoicesshared.LineTypeProration,
+		Description: fmt.Sprintf("Proration to plan %s v%d", plan.ID, plan.Version),
+		Quantity:    1,
+		Amount:      req.Proration,
+	}}
+	if req.Proration.IsPositive() {
+		user, err := s.usersService.GetUser(ctx, subscription.UserID.Hex())
+		if err != nil {
+			return nil, err
+		}
+		tax, err := s.tax(ctx, user, req.Proration)
+		if err != nil {
+			return nil, err
+		}
+		if tax.Tax.IsPositive() {
+			lines = append(lines, taxLine(tax))
+		}
+		payment, _, err = s.pay(ctx, user, tax.Gross, ledgerReference)
+		if err != nil {
+			return nil, err
+		}
+		paid = payment.Amount
+	}
+
+	if req.Immediate {
+		err = applyPlan(subscription, plan, subscription.Price.Currency)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		planID, _ := primitive.ObjectIDFromHex(plan.ID)
+		subscription.PendingPlanID = &planID
+		subscription.PendingPlanVersion = plan.Version
+	}
+	subscription.UpdatedAt = time.Now()
+
+	err = s.subscriptionsStore.Update(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("subscription plan changed: subscription_id=%v, plan_id=%v, proration=%v, immediate=%v\n",
+		subscription.ID.Hex(), plan.ID, req.Proration, req.Immediate)
+
+	if req.Proration.IsPositive() {
+		s.issueInvoice(ctx, &types.CreateInvoiceInput{
+			UserID:          subscription.UserID.Hex(),
+			SubscriptionID:  subscription.ID.Hex(),
+			Status:          invoicesshared.StatusPaid,
+			Lines:           lines,
+			Paid:            paid,
+			PeriodStart:     time.Now(),
+			PeriodEnd:       subscription.ExpiresAt,
+			LedgerReference: ledgerReference,
+			Gateway:         payment.Gateway,
+			PaymentID:       payment.ID,
+		})
+	}
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) ApplyCoupon(ctx context.Context, req *types.ApplyCouponSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.UserID.Hex() != req.UserID {
+		return nil, fmt.Errorf("invalid user to apply coupon: user_id=%v, subscription_id=%v",
+			req.UserID, req.ID)
+	}
+	if subscription.Canceled || subscription.Disabled {
+		return nil, fmt.Errorf("subscription is not active: subscription_id=%v", req.ID)
+	}
+	coupon, err := s.couponsService.GetCouponByCode(ctx, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCouponCurrency(coupon, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.temporalClient.SignalWorkflow(ctx, req.ID, "", SignalApplyCoupon, ApplyCouponSignal{Code: coupon.Code})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("subscription coupon requested: subscription_id=%v, code=%v\n", req.ID, coupon.Code)
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) RedeemCoupon(ctx context.Context, req *types.RedeemCouponRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	coupon, err := s.couponsService.RedeemCoupon(ctx, &types.CouponRedemptionInput{
+		Code:           req.Code,
+		SubscriptionID: req.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.Discount = newDiscount(coupon)
+	subscription.UpdatedAt = time.Now()
+
+	err = s.subscriptionsStore.Update(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("subscription coupon applied: subscription_id=%v, code=%v\n", subscription.ID.Hex(), coupon.Code)
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) MarkPastDue(ctx context.Context, req *types.MarkPastDueSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.Status = shared.StatusPastDue
+	pastDueSince := workflowTime(req.PastDueSince)
+	subscription.PastDueSince = &pastDueSince
+	graceEndsAt := req.GraceEndsAt
+	subscription.GraceEndsAt = &graceEndsAt
+	subscription.UpdatedAt = time.Now()
+
+	err = s.subscriptionsStore.Update(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("subscription past due: ", subscription.ID.Hex())
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) RetryPayment(ctx context.Context, req *types.RetryPaymentRequest) ([]*types.SubscriptionOutput, error) {
+	userID, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, err := s.subscriptionsStore.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.SubscriptionOutput, 0)
+	for index := range subscriptions {
+		if subscriptions[index].Status != shared.StatusPastDue {
+			continue
+		}
+		err = s.temporalClient.SignalWorkflow(ctx, subscriptions[index].ID.Hex(), "", SignalRetryPayment, nil)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("subscription payment retry requested: ", subscriptions[index].ID.Hex())
+		out = append(out, subscriptions[index].Out())
+	}
+	return out, nil
+}
+
+func (s *subscriptionsService) Pause(ctx context.Context, req *types.PauseSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.UserID.Hex() != req.UserID {
+		return nil, fmt.Errorf("invalid user to pause subscription: user_id=%v, subscription_id=%v",
+			req.UserID, req.ID)
+	}
+	if subscription.Canceled || subscription.Disabled || subscription.Status != shared.StatusActive {
+		return nil, fmt.Errorf("subscription is not active: subscription_id=%v", req.ID)
+	}
+	if req.ResumeAt != nil && !req.ResumeAt.After(time.Now()) {
+		return nil, fmt.Errorf("invalid resume date: subscription_id=%v, resume_at=%v", req.ID, req.ResumeAt)
+	}
+
+	err = s.temporalClient.SignalWorkflow(ctx, req.ID, "", SignalPauseSubscription, PauseSignal{ResumeAt: req.ResumeAt})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("subscription pause requested: ", req.ID)
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) Resume(ctx context.Context, req *types.ResumeSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.UserID.Hex() != req.UserID {
+		return nil, fmt.Errorf("invalid user to resume subscription: user_id=%v, subscription_id=%v",
+			req.UserID, req.ID)
+	}
+	if subscription.Status != shared.StatusPaused {
+		return nil, fmt.Errorf("subscription is not paused: subscription_id=%v", req.ID)
+	}
+
+	err = s.temporalClient.SignalWorkflow(ctx, req.ID, "", SignalResumeSubscription, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("subscription resume requested: ", req.ID)
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) MarkPaused(ctx context.Context, req *types.MarkPausedSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Status == shared.StatusPaused {
+		return subscription.Out(), nil
+	}
+
+	pausedAt := workflowTime(req.PausedAt)
+	subscription.Status = shared.StatusPaused
+	subscription.PausedAt = &pausedAt
+	subscription.ResumeAt = req.ResumeAt
+	subscription.Pauses = append(subscription.Pauses, &models.Pause{
+		PausedAt: pausedAt,
+		ResumeAt: req.ResumeAt,
+	})
+	subscription.UpdatedAt = time.Now()
+
+	err = s.subscriptionsStore.Update(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("subscription paused: ", subscription.ID.Hex())
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) MarkResumed(ctx context.Context, req *types.MarkResumedSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Status != shared.StatusPaused || subscription.PausedAt == nil {
+		return subscription.Out(), nil
+	}
+
+	resumedAt := workflowTime(req.ResumedAt)
+	remaining := subscription.ExpiresAt.Sub(*subscription.PausedAt)
+	subscription.Status = shared.StatusActive
+	subscription.ExpiresAt = resumedAt.Add(remaining)
+	subscription.BillingAnchor = subscription.ExpiresAt.Day()
+	subscription.PausedAt = nil
+	subscription.ResumeAt = nil
+	if len(subscription.Pauses) > 0 {
+		subscription.Pauses[len(subscription.Pauses)-1].ResumedAt = &resumedAt
+	}
+	subscription.UpdatedAt = time.Now()
+
+	err = s.subscriptionsStore.Update(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("subscription resumed: ", subscription.ID.Hex())
+
+	return subscription.Out(), nil
+}
+
+func (s *subscriptionsService) RecordUsage(ctx context.Context, req *types.RecordUsageRequest) (*types.UsageRecordOutput, error) {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.subscriptionsStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.UserID.Hex() != req.UserID {
+		return nil, fmt.Errorf("invalid user to record usage: user_id=%v, subscription_id=%v",
+			req.UserID, req.ID)
+	}
+	if !subscription.GrantsAccess(time.Now()) {
+		return nil, fmt.Errorf("subscription is not active: subscription_id=%v", req.ID)
+	}
+	if subscription.MeteredPrice(req.Feature) == nil {
+		return nil, fmt.Errorf("feature is not metered: subscription_id=%v, feature=%v", req.ID, req.Feature)
+	}
+
+	in := &types.RecordUsageInput{
+		SubscriptionID: req.ID,
+		UserID:         req.UserID,
+		Feature:        req.Feature,
+		Quantity:       req.Quantity,
+		Activation:     subscription.Activations,
+	}
+	out, err := s.usageService.RecordUsage(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	signal := UsageSignal{
+		Feature:    out.Feature,
+		Quantity:   out.Quantity,
+		Activation: out.Activation,
+	}
+	err = s.temporalClient.SignalWorkflow(ctx, req.ID, "", SignalRecordUsage, signal)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("subscription usage recorded: subscription_id=%v, feature=%v, quantity=%d\n",
+		req.ID, out.Feature, out.Quantity)
+
+	return out, nil
+}
+
+func (s *subscriptionsService) GetUsage(ctx context.Context, req *types.GetUsageRequest) ([]*types.UsageOutput, error) {
+	return s.usageService.GetUsage(ctx, req.ID, req.Activation)
+}
+
+func (s *subscriptionsService) GetSubscriptions(ctx context.Context) ([]*types.SubscriptionOutput, error) {
+	subscriptions, err := s.subscriptionsStore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.SubscriptionOutput, 0, len(subscriptions))
+	for index := range subscriptions {
+		out = append(out, subscriptions[index].Out())
+	}
+	return out, nil
+}
+
+func (s *subscriptionsService) GetSubscription(ctx context.Context, req *types.GetSubscriptionRequest) (*types.SubscriptionOutput, error) {
+	res, err := s.temporalClient.QueryWorkflow(ctx, req.ID, "", QuerySubscriptionState, nil)
+	if err != nil {
+		return nil, err
+	}
+	var state SubscriptionState
+	err = res.Get(&state)
+	if err != nil {
+		return nil, err
+	}
+	return state.Out(), nil
+}
+
+func (s *subscriptionsService) SearchWorkflows(ctx context.Context, req *types.SearchSubscriptionWorkflowsRequest) (*types.SubscriptionWorkflowsOutput, error) {
+	res, err := s.temporalClient.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		PageSize:      req.PageSize,
+		NextPageToken: req.NextPageToken,
+		Query:         searchQuery(req),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := &types.SubscriptionWorkflowsOutput{
+		Workflows:     make([]*types.SubscriptionWorkflowOutput, 0, len(res.GetExecutions())),
+		NextPageToken: res.GetNextPageToken(),
+	}
+	for _, info := range res.GetExecutions() {
+		workflow, err := workflowOut(info)
+		if err != nil {
+			return nil, err
+		}
+		out.Workflows = append(out.Workflows, workflow)
+	}
+	return out, nil
+}
+
+// applyPendingPlan moves the subscription to the version of its pending plan it
+// was changed to, if it has one. Changes requested before pending versions
+// were kept take the current version.
+func (s *subscriptionsService) applyPendingPlan(ctx context.Context, subscription *models.Subscription) error {
+	if subscription.PendingPlanID == nil {
+		return nil
+	}
+	var plan *types.PlanOutput
+	var err error
+	if subscription.PendingPlanVersion == 0 {
+		plan, err = s.plansService.GetPlan(ctx, subscription.PendingPlanID.Hex())
+	} else {
+		plan, err = s.plansService.GetPlanVersion(ctx, subscription.PendingPlanID.Hex(), subscription.PendingPlanVersion)
+	}
+	if err != nil {
+		return err
+	}
+	return applyPlan(subscription, plan, subscription.Price.Currency)
+}
+
+func applyPlan(subscription *models.Subscription, plan *types.PlanOutput, currency string) error {
+	planID, err := primitive.ObjectIDFromHex(plan.ID)
+	if err != nil {
+		return err
+	}
+	subscription.PlanID = planID
+	subscription.PlanVersion = plan.Version
+	subscription.PendingPlanID = nil
+	subscription.PendingPlanVersion = 0
+	subscription.Price = planPrice(plan, currency)
+	subscription.Interval = plan.Interval
+	subscription.IntervalCount = plan.IntervalCount
+	subscription.Features = make([]*models.Feature, 0, len(plan.Features))
+	for index := range plan.Features {
+		subscription.Features = append(subscription.Features, &models.Feature{Name: plan.Features[index].Name})
+	}
+	subscription.MeteredPrices = make([]*models.MeteredPrice, 0, len(plan.MeteredPrices))
+	for index := range plan.MeteredPrices {
+		meteredPrice := &models.MeteredPrice{
+			Feature:   plan.MeteredPrices[index].Feature,
+			Pricing:   plan.MeteredPrices[index].Pricing,
+			UnitPrice: plan.MeteredPrices[index].UnitPrice,
+			Tiers:     make([]*models.Tier, 0, len(plan.MeteredPrices[index].Tiers)),
+		}
+		for _, tier := range plan.MeteredPrices[index].Tiers {
+			meteredPrice.Tiers = append(meteredPrice.Tiers, &models.Tier{
+				UpTo:      tier.UpTo,
+				UnitPrice: tier.UnitPrice,
+				FlatPrice: tier.FlatPrice,
+			})
+		}
+		subscription.MeteredPrices = append(subscription.MeteredPrices, meteredPrice)
+	}
+	return nil
+}
+
+func planPrice(plan *types.PlanOutput, currency string) money.Money {
+	for index := range plan.Prices {
+		if plan.Prices[index].Currency == currency {
+			return plan.Prices[index]
+		}
+	}
+	return plan.Price
+}
+
+func checkCouponCurrency(coupon *types.CouponOutput, subscription *models.Subscription) error {
+	if coupon.Type == couponsshared.TypeFixed && coupon.AmountOff.Currency != subscription.Price.Currency {
+		return fmt.Errorf("coupon currency differs from subscription: code=%v, currency=%v", coupon.Code, coupon.AmountOff.Currency)
+	}
+	return nil
+}
+
+func newDiscount(coupon *types.CouponOutput) *models.Discount {
+	couponID, _ := primitive.ObjectIDFromHex(coupon.ID)
+	return &models.Discount{
+		CouponID:        couponID,
+		Code:            coupon.Code,
+		Type:            coupon.Type,
+		PercentOff:      coupon.PercentOff,
+		AmountOff:       coupon.AmountOff,
+		Duration:        coupon.Duration,
+		CyclesRemaining: coupon.DurationCycles,
+		AppliedAt:       time.Now(),
+	}
+}
+
+func (s *subscriptionsService) releaseTrial(ctx context.Context, userID, planID string) {
+	_, err := s.usersService.ReleaseTrial(ctx, userID, planID)
+	if err != nil {
+		log.Printf("trial release failed: user_id=%v, plan_id=%v, error=%v\n", userID, planID, err)
+	}
+}
+
+func (s *subscriptionsService) releaseCoupon(ctx context.Context, redemption *types.CouponRedemptionInput) {
+	if redemption == nil {
+		return
+	}
+	_, err := s.couponsService.ReleaseCoupon(ctx, redemption)
+	if err != nil {
+		log.Printf("coupon release failed: code=%v, subscription_id=%v, error=%v\n",
+			redemption.Code, redemption.SubscriptionID, err)
+	}
+}
+
+func referenceID(subscription *models.Subscription, activation int) string {
+	return fmt.Sprintf("%s:%d", subscription.ID.Hex(), activation)
+}
+
+// workflowTime returns the workflow time an activity was scheduled at, or the
+// current time for activities scheduled before workflows passed it.
+func workflowTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
+
+func contains(values []string, value string) bool {
+	for index := range values {
+		if values[index] == value {
+			return true
+		}
+	}
+	return false
 }
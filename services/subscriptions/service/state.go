@@ -1,6 +1,9 @@
 package service
 
 import (
+	"go-subscriptions-workflow/billing"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/subscriptions/shared"
 	"go-subscriptions-workflow/types"
 	"time"
 )
@@ -9,78 +12,390 @@ const (
 	TaskQueueName            = "SubscriptionsTaskQueue"
 	QuerySubscriptionState   = "QuerySubscriptionState"
 	SignalCancelSubscription = "SignalCancelSubscription"
+	SignalChangePlan         = "SignalChangePlan"
+	SignalApplyCoupon        = "SignalApplyCoupon"
+	SignalRetryPayment       = "SignalRetryPayment"
+	SignalPauseSubscription  = "SignalPauseSubscription"
+	SignalResumeSubscription = "SignalResumeSubscription"
+	SignalCancelAtPeriodEnd  = "SignalCancelAtPeriodEnd"
+	SignalRecordUsage        = "SignalRecordUsage"
+	SignalRefund             = "SignalRefund"
 )
 
 type SubscriptionState struct {
-	ID          string
-	UserID      string
-	Price       float64
-	Features    []*Feature
-	Activations int
-	ActivatedAt time.Time
-	ExpiresAt   time.Time
-	Canceled    bool
-	CanceledAt  *time.Time
-	Disabled    bool
-	DisabledAt  *time.Time
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID                 string
+	UserID             string
+	PlanID             string
+	PlanVersion        int
+	PendingPlanID      string
+	PendingPlanVersion int
+	Status             string
+	Price              money.Money
+	Interval           string
+	IntervalCount      int
+	BillingAnchor      int
+	Features           []*Feature
+	MeteredPrices      []*MeteredPrice
+	Usage              []*Usage
+	Activations        int
+	TrialEndsAt        *time.Time
+	PastDueSince       *time.Time
+	GraceEndsAt        *time.Time
+	PausedAt           *time.Time
+	ResumeAt           *time.Time
+	Pauses             []*Pause
+	Discount           *Discount
+	LastCharge         *Charge
+	ActivatedAt        time.Time
+	ExpiresAt          time.Time
+	Canceled           bool
+	CanceledAt         *time.Time
+	CancelAtPeriodEnd  bool
+	AccessEndsAt       *time.Time
+	Disabled           bool
+	DisabledAt         *time.Time
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
 }
 
 type Feature struct {
 	Name string
 }
 
+type MeteredPrice struct {
+	Feature   string
+	Pricing   string
+	UnitPrice money.Money
+	Tiers     []billing.Tier
+}
+
+type Usage struct {
+	Feature  string
+	Quantity int64
+}
+
+type Pause struct {
+	PausedAt  time.Time
+	ResumeAt  *time.Time
+	ResumedAt *time.Time
+}
+
+type Discount struct {
+	CouponID        string
+	Code            string
+	Type            string
+	PercentOff      float64
+	AmountOff       money.Money
+	Duration        string
+	CyclesRemaining int
+	AppliedAt       time.Time
+}
+
+type Charge struct {
+	Amount          money.Money
+	Usage           money.Money
+	Discount        money.Money
+	Tax             money.Money
+	Paid            money.Money
+	CouponCode      string
+	Gateway         string
+	PaymentMethodID string
+	PaymentID       string
+	ChargedAt       time.Time
+}
+
+type DunningPolicy struct {
+	Schedule    []time.Duration
+	GracePeriod time.Duration
+}
+
+func NewDunningPolicy() DunningPolicy {
+	return DunningPolicy{
+		Schedule:    shared.DunningSchedule(),
+		GracePeriod: shared.GracePeriod(),
+	}
+}
+
+// ContinueAsNewPolicy bounds the history of a workflow run. The run continues
+// as new after Renewals renewals, or after Iterations passes through the main
+// loop, which stands in for the history length the SDK does not expose.
+type ContinueAsNewPolicy struct {
+	Renewals   int
+	Iterations int
+}
+
+func NewContinueAsNewPolicy() ContinueAsNewPolicy {
+	return ContinueAsNewPolicy{
+		Renewals:   shared.ContinueAsNewRenewals(),
+		Iterations: shared.ContinueAsNewIterations(),
+	}
+}
+
+func (p ContinueAsNewPolicy) Due(renewals, iterations int) bool {
+	return (p.Renewals > 0 && renewals >= p.Renewals) || (p.Iterations > 0 && iterations >= p.Iterations)
+}
+
+type ChangePlanSignal struct {
+	PlanID      string
+	PlanVersion int
+	Price       money.Money
+}
+
+type ApplyCouponSignal struct {
+	Code string
+}
+
+type PauseSignal struct {
+	ResumeAt *time.Time
+}
+
+type RefundSignal struct {
+	RefundID  string
+	InvoiceID string
+	Amount    *money.Money
+	Reason    string
+	Cancel    bool
+}
+
+type UsageSignal struct {
+	Feature    string
+	Quantity   int64
+	Activation int
+}
+
 func (s *SubscriptionState) HasExpired(t time.Time) bool {
 	return s.ExpiresAt.Before(t)
 }
 
-func (s *SubscriptionState) Expiration() time.Duration {
-	return s.ExpiresAt.Sub(s.ActivatedAt)
+func (s *SubscriptionState) AddUsage(signal UsageSignal) {
+	if signal.Activation != s.Activations {
+		return
+	}
+	for index := range s.Usage {
+		if s.Usage[index].Feature == signal.Feature {
+			s.Usage[index].Quantity += signal.Quantity
+			return
+		}
+	}
+	s.Usage = append(s.Usage, &Usage{Feature: signal.Feature, Quantity: signal.Quantity})
+}
+
+func (s *SubscriptionState) usageAmount(usage *Usage) money.Money {
+	for index := range s.MeteredPrices {
+		if s.MeteredPrices[index].Feature == usage.Feature {
+			meteredPrice := s.MeteredPrices[index]
+			return billing.MeteredAmount(meteredPrice.Pricing, meteredPrice.UnitPrice, meteredPrice.Tiers, usage.Quantity)
+		}
+	}
+	return money.Zero(s.Price.Currency)
+}
+
+func (s *SubscriptionState) Prorate(price money.Money, now time.Time) (money.Money, error) {
+	period := s.ExpiresAt.Sub(s.ActivatedAt)
+	remaining := s.ExpiresAt.Sub(now)
+	if period <= 0 || remaining <= 0 {
+		return money.Zero(s.Price.Currency), nil
+	}
+	difference, err := price.Sub(s.Price)
+	if err != nil {
+		return difference, err
+	}
+	return difference.Ratio(float64(remaining) / float64(period)), nil
 }
 
 func NewState(subscription *types.SubscriptionOutput) SubscriptionState {
 	state := SubscriptionState{
-		ID:          subscription.ID,
-		UserID:      subscription.UserID,
-		Price:       subscription.Price,
-		Activations: subscription.Activations,
-		ActivatedAt: subscription.ActivatedAt,
-		ExpiresAt:   subscription.ExpiresAt,
-		Canceled:    subscription.Canceled,
-		CanceledAt:  subscription.CanceledAt,
-		Disabled:    subscription.Disabled,
-		DisabledAt:  subscription.DisabledAt,
-		CreatedAt:   subscription.CreatedAt,
-		UpdatedAt:   subscription.UpdatedAt,
+		ID:                 subscription.ID,
+		UserID:             subscription.UserID,
+		PlanID:             subscription.PlanID,
+		PlanVersion:        subscription.PlanVersion,
+		PendingPlanID:      subscription.PendingPlanID,
+		PendingPlanVersion: subscription.PendingPlanVersion,
+		Status:             subscription.Status,
+		Price:              subscription.Price,
+		Interval:           subscription.Interval,
+		IntervalCount:      subscription.IntervalCount,
+		BillingAnchor:      subscription.BillingAnchor,
+		Activations:        subscription.Activations,
+		TrialEndsAt:        subscription.TrialEndsAt,
+		PastDueSince:       subscription.PastDueSince,
+		GraceEndsAt:        subscription.GraceEndsAt,
+		PausedAt:           subscription.PausedAt,
+		ResumeAt:           subscription.ResumeAt,
+		ActivatedAt:        subscription.ActivatedAt,
+		ExpiresAt:          subscription.ExpiresAt,
+		Canceled:           subscription.Canceled,
+		CanceledAt:         subscription.CanceledAt,
+		CancelAtPeriodEnd:  subscription.CancelAtPeriodEnd,
+		AccessEndsAt:       subscription.AccessEndsAt,
+		Disabled:           subscription.Disabled,
+		DisabledAt:         subscription.DisabledAt,
+		CreatedAt:          subscription.CreatedAt,
+		UpdatedAt:          subscription.UpdatedAt,
+	}
+	if subscription.Discount != nil {
+		state.Discount = &Discount{
+			CouponID:        subscription.Discount.CouponID,
+			Code:            subscription.Discount.Code,
+			Type:            subscription.Discount.Type,
+			PercentOff:      subscription.Discount.PercentOff,
+			AmountOff:       subscription.Discount.AmountOff,
+			Duration:        subscription.Discount.Duration,
+			CyclesRemaining: subscription.Discount.CyclesRemaining,
+			AppliedAt:       subscription.Discount.AppliedAt,
+		}
+	}
+	if subscription.LastCharge != nil {
+		state.LastCharge = &Charge{
+			Amount:          subscription.LastCharge.Amount,
+			Usage:           subscription.LastCharge.Usage,
+			Discount:        subscription.LastCharge.Discount,
+			Tax:             subscription.LastCharge.Tax,
+			Paid:            subscription.LastCharge.Paid,
+			CouponCode:      subscription.LastCharge.CouponCode,
+			Gateway:         subscription.LastCharge.Gateway,
+			PaymentMethodID: subscription.LastCharge.PaymentMethodID,
+			PaymentID:       subscription.LastCharge.PaymentID,
+			ChargedAt:       subscription.LastCharge.ChargedAt,
+		}
+	}
+	state.Pauses = make([]*Pause, 0, len(subscription.Pauses))
+	for index := range subscription.Pauses {
+		pause := &Pause{
+			PausedAt:  subscription.Pauses[index].PausedAt,
+			ResumeAt:  subscription.Pauses[index].ResumeAt,
+			ResumedAt: subscription.Pauses[index].ResumedAt,
+		}
+		state.Pauses = append(state.Pauses, pause)
 	}
 	state.Features = make([]*Feature, 0, len(subscription.Features))
 	for index := range subscription.Features {
 		feature := &Feature{Name: subscription.Features[index].Name}
 		state.Features = append(state.Features, feature)
 	}
+	state.MeteredPrices = make([]*MeteredPrice, 0, len(subscription.MeteredPrices))
+	for index := range subscription.MeteredPrices {
+		meteredPrice := &MeteredPrice{
+			Feature:   subscription.MeteredPrices[index].Feature,
+			Pricing:   subscription.MeteredPrices[index].Pricing,
+			UnitPrice: subscription.MeteredPrices[index].UnitPrice,
+			Tiers:     make([]billing.Tier, 0, len(subscription.MeteredPrices[index].Tiers)),
+		}
+		for _, tier := range subscription.MeteredPrices[index].Tiers {
+			meteredPrice.Tiers = append(meteredPrice.Tiers, billing.Tier{
+				UpTo:      tier.UpTo,
+				UnitPrice: tier.UnitPrice,
+				FlatPrice: tier.FlatPrice,
+			})
+		}
+		state.MeteredPrices = append(state.MeteredPrices, meteredPrice)
+	}
+	state.Usage = make([]*Usage, 0, len(subscription.Usage))
+	for index := range subscription.Usage {
+		usage := &Usage{
+			Feature:  subscription.Usage[index].Feature,
+			Quantity: subscription.Usage[index].Quantity,
+		}
+		state.Usage = append(state.Usage, usage)
+	}
 	return state
 }
 
 func (s *SubscriptionState) Out() *types.SubscriptionOutput {
 	out := &types.SubscriptionOutput{
-		ID:          s.ID,
-		UserID:      s.UserID,
-		Price:       s.Price,
-		Activations: s.Activations,
-		ActivatedAt: s.ActivatedAt,
-		ExpiresAt:   s.ExpiresAt,
-		Canceled:    s.Canceled,
-		CanceledAt:  s.CanceledAt,
-		Disabled:    s.Disabled,
-		DisabledAt:  s.DisabledAt,
-		CreatedAt:   s.CreatedAt,
-		UpdatedAt:   s.UpdatedAt,
+		ID:                 s.ID,
+		UserID:             s.UserID,
+		PlanID:             s.PlanID,
+		PlanVersion:        s.PlanVersion,
+		PendingPlanID:      s.PendingPlanID,
+		PendingPlanVersion: s.PendingPlanVersion,
+		Status:             s.Status,
+		Price:              s.Price,
+		Interval:           s.Interval,
+		IntervalCount:      s.IntervalCount,
+		BillingAnchor:      s.BillingAnchor,
+		Activations:        s.Activations,
+		TrialEndsAt:        s.TrialEndsAt,
+		PastDueSince:       s.PastDueSince,
+		GraceEndsAt:        s.GraceEndsAt,
+		PausedAt:           s.PausedAt,
+		ResumeAt:           s.ResumeAt,
+		ActivatedAt:        s.ActivatedAt,
+		ExpiresAt:          s.ExpiresAt,
+		Canceled:           s.Canceled,
+		CanceledAt:         s.CanceledAt,
+		CancelAtPeriodEnd:  s.CancelAtPeriodEnd,
+		AccessEndsAt:       s.AccessEndsAt,
+		Disabled:           s.Disabled,
+		DisabledAt:         s.DisabledAt,
+		CreatedAt:          s.CreatedAt,
+		UpdatedAt:          s.UpdatedAt,
+	}
+	if s.Discount != nil {
+		out.Discount = &types.DiscountOutput{
+			CouponID:        s.Discount.CouponID,
+			Code:            s.Discount.Code,
+			Type:            s.Discount.Type,
+			PercentOff:      s.Discount.PercentOff,
+			AmountOff:       s.Discount.AmountOff,
+			Duration:        s.Discount.Duration,
+			CyclesRemaining: s.Discount.CyclesRemaining,
+			AppliedAt:       s.Discount.AppliedAt,
+		}
+	}
+	if s.LastCharge != nil {
+		out.LastCharge = &types.ChargeOutput{
+			Amount:          s.LastCharge.Amount,
+			Usage:           s.LastCharge.Usage,
+			Discount:        s.LastCharge.Discount,
+			Tax:             s.LastCharge.Tax,
+			Paid:            s.LastCharge.Paid,
+			CouponCode:      s.LastCharge.CouponCode,
+			Gateway:         s.LastCharge.Gateway,
+			PaymentMethodID: s.LastCharge.PaymentMethodID,
+			PaymentID:       s.LastCharge.PaymentID,
+			ChargedAt:       s.LastCharge.ChargedAt,
+		}
+	}
+	out.Pauses = make([]*types.PauseOutput, 0, len(s.Pauses))
+	for index := range s.Pauses {
+		pause := &types.PauseOutput{
+			PausedAt:  s.Pauses[index].PausedAt,
+			ResumeAt:  s.Pauses[index].ResumeAt,
+			ResumedAt: s.Pauses[index].ResumedAt,
+		}
+		out.Pauses = append(out.Pauses, pause)
 	}
 	out.Features = make([]*types.FeatureOutput, 0, len(out.Features))
 	for index := range s.Features {
 		feature := &types.FeatureOutput{Name: s.Features[index].Name}
 		out.Features = append(out.Features, feature)
 	}
+	out.MeteredPrices = make([]*types.MeteredPriceOutput, 0, len(s.MeteredPrices))
+	for index := range s.MeteredPrices {
+		meteredPrice := &types.MeteredPriceOutput{
+			Feature:   s.MeteredPrices[index].Feature,
+			Pricing:   s.MeteredPrices[index].Pricing,
+			UnitPrice: s.MeteredPrices[index].UnitPrice,
+			Tiers:     make([]*types.TierOutput, 0, len(s.MeteredPrices[index].Tiers)),
+		}
+		for _, tier := range s.MeteredPrices[index].Tiers {
+			meteredPrice.Tiers = append(meteredPrice.Tiers, &types.TierOutput{
+				UpTo:      tier.UpTo,
+				UnitPrice: tier.UnitPrice,
+				FlatPrice: tier.FlatPrice,
+			})
+		}
+		out.MeteredPrices = append(out.MeteredPrices, meteredPrice)
+	}
+	out.Usage = make([]*types.UsageOutput, 0, len(s.Usage))
+	for index := range s.Usage {
+		usage := &types.UsageOutput{
+			Feature:  s.Usage[index].Feature,
+			Quantity: s.Usage[index].Quantity,
+			Amount:   s.usageAmount(s.Usage[index]),
+		}
+		out.Usage = append(out.Usage, usage)
+	}
 	return out
 }
@@ -0,0 +1,41 @@
+package service
+
+// Change IDs for workflow.GetVersion in SubscriptionsWorkflow.
+//
+// Workflows replay their history on every worker restart, so a change that
+// adds, removes or reorders commands (activities, timers, side effects, child
+// workflows, continue-as-new) breaks the runs already in progress. Gate every
+// such change behind a branch:
+//
+//	if workflow.GetVersion(ctx, changeX, workflow.DefaultVersion, 1) == 1 {
+//		// new behavior
+//	}
+//
+//   - Add a change ID here for each change, and never reuse or rename one.
+//   - Call GetVersion where the new commands start. Runs whose history already
+//     passed that point keep the old branch until they end or continue as new.
+//   - Changing the same code again bumps maxSupported and adds a branch for it.
+//   - Drop a branch, and raise minSupported past it, only once no open run can
+//     still take it.
+//   - Export the history of a run on the old version into testdata and add it to
+//     the replay tests before merging.
+//
+// Changes that only touch activity code replay unchanged and need no version.
+// A new signal does: handling it restarts the period timer, so runs from before
+// it drop the signal and keep waiting on the timer they started.
+const (
+	changeContinueAsNew     = "continue-as-new"
+	changeReceipts          = "receipts"
+	changeSearchAttributes  = "search-attributes"
+	changeChargeSaga        = "charge-saga"
+	changeImmediateCancel   = "immediate-cancel"
+	changePlanChanges       = "plan-changes"
+	changeCoupons           = "coupons"
+	changeDunning           = "dunning"
+	changePauses            = "pauses"
+	changeCancelAtPeriodEnd = "cancel-at-period-end"
+	changeUsage             = "usage"
+	changeRefunds           = "refunds"
+	changePausedLoop        = "paused-loop"
+	changeDeclinesOnly      = "declines-only"
+)
@@ -11,6 +11,6 @@ func Worker(temporalClient client.Client, svc SubscriptionsServiceServer) {
 	log.Println("subscriptions worker starting")
 	w := worker.New(temporalClient, TaskQueueName, worker.Options{})
 	w.RegisterWorkflow(SubscriptionsWorkflow)
-	w.RegisterActivity(&Activities{svc: svc})
+	w.RegisterActivity(NewActivities(svc))
 	util.PanicOnError(w.Run(worker.InterruptCh()))
 }
\ No newline at end of file
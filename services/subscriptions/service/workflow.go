@@ -1,9 +1,11 @@
 package service
 
 import (
+	"errors"
+	"go-subscriptions-workflow/services/subscriptions/shared"
+	"go-subscriptions-workflow/types"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
-	"strings"
 	"time"
 )
 
@@ -20,47 +22,205 @@ func SubscriptionsWorkflow(ctx workflow.Context, state SubscriptionState, activi
 		return state, err
 	}
 
-	cancelSelector := workflow.NewSelector(ctx)
 	cancelChannel := workflow.GetSignalChannel(ctx, SignalCancelSubscription)
-	cancelSelector.AddReceive(cancelChannel, func(ch workflow.ReceiveChannel, _ bool) {
-		var cancelSignal bool
-		ch.Receive(ctx, &cancelSignal)
-		state.Canceled = true
-		state.CreatedAt = time.Now()
-	})
+	changePlanChannel := workflow.GetSignalChannel(ctx, SignalChangePlan)
+	applyCouponChannel := workflow.GetSignalChannel(ctx, SignalApplyCoupon)
+	retryPaymentChannel := workflow.GetSignalChannel(ctx, SignalRetryPayment)
+	pauseChannel := workflow.GetSignalChannel(ctx, SignalPauseSubscription)
+	resumeChannel := workflow.GetSignalChannel(ctx, SignalResumeSubscription)
+	cancelAtPeriodEndChannel := workflow.GetSignalChannel(ctx, SignalCancelAtPeriodEnd)
+	usageChannel := workflow.GetSignalChannel(ctx, SignalRecordUsage)
+	refundChannel := workflow.GetSignalChannel(ctx, SignalRefund)
 
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout:    time.Second * 10,
 		RetryPolicy:            &temporal.RetryPolicy{
 			MaximumAttempts:        3,
-			NonRetryableErrorTypes: []string{ErrInsufficientFunds.Error()},
+			NonRetryableErrorTypes: []string{ErrorTypeInsufficientFunds},
 		},
 	}
 
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
-	for {
-		_, err = workflow.AwaitWithTimeout(ctx, state.Expiration(), func() bool {
-			return state.Canceled || state.Disabled
+	var continueAsNew ContinueAsNewPolicy
+	if workflow.GetVersion(ctx, changeContinueAsNew, workflow.DefaultVersion, 1) == 1 {
+		err = workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+			return NewContinueAsNewPolicy()
+		}).Get(&continueAsNew)
+		if err != nil {
+			return state, err
+		}
+	}
+
+	renewals, iterations := 0, 0
+	for !state.Canceled && !state.Disabled {
+		upsertSearchAttributes(ctx, state)
+
+		if continueAsNew.Due(renewals, iterations) && !hasPendingSignals(ctx, cancelChannel, changePlanChannel,
+			applyCouponChannel, retryPaymentChannel, pauseChannel, resumeChannel, cancelAtPeriodEndChannel,
+			usageChannel, refundChannel) {
+			logger.Debug("subscription workflow continued as new", "id", state.ID, "renewals", renewals, "iterations", iterations)
+			return state, workflow.NewContinueAsNewError(ctx, SubscriptionsWorkflow, state, activities)
+		}
+		iterations++
+
+		// Signals that a run handles restart the timer, as they may move the
+		// period. Runs replaying a history from before a signal existed drop it
+		// and keep waiting, and defer a cancel until after the next charge.
+		expired, handled, cancelPending := false, false, false
+
+		// A paused subscription has no renewal timer; it waits for the resume
+		// signal or date, and keeps handling the other signals meanwhile.
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		selector := workflow.NewSelector(ctx)
+		switch {
+		case state.Status != shared.StatusPaused:
+			selector.AddFuture(workflow.NewTimer(timerCtx, state.ExpiresAt.Sub(workflow.Now(ctx))), func(f workflow.Future) {
+				expired = true
+			})
+		case state.ResumeAt != nil:
+			selector.AddFuture(workflow.NewTimer(timerCtx, state.ResumeAt.Sub(workflow.Now(ctx))), func(f workflow.Future) {
+				state = resume(ctx, state, activities)
+				handled = true
+			})
+		}
+		selector.AddReceive(cancelChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			if workflow.GetVersion(ctx, changeImmediateCancel, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				ch.Receive(ctx, nil)
+				cancelPending = true
+				return
+			}
+			state = receiveCancel(ctx, ch, state)
+			handled = true
+		})
+		selector.AddReceive(retryPaymentChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			ch.Receive(ctx, nil)
+			handled = workflow.GetVersion(ctx, changeDunning, workflow.DefaultVersion, 1) == 1
+		})
+		selector.AddReceive(resumeChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			ch.Receive(ctx, nil)
+			if workflow.GetVersion(ctx, changePauses, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				return
+			}
+			if state.Status == shared.StatusPaused {
+				state = resume(ctx, state, activities)
+			}
+			handled = true
+		})
+		selector.AddReceive(cancelAtPeriodEndChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			var cancelAtPeriodEnd bool
+			ch.Receive(ctx, &cancelAtPeriodEnd)
+			if workflow.GetVersion(ctx, changeCancelAtPeriodEnd, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				return
+			}
+			state = setCancelAtPeriodEnd(ctx, state, cancelAtPeriodEnd, activities)
+			handled = true
+		})
+		selector.AddReceive(pauseChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			var pauseSignal PauseSignal
+			ch.Receive(ctx, &pauseSignal)
+			if workflow.GetVersion(ctx, changePauses, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				return
+			}
+			if workflow.GetVersion(ctx, changePausedLoop, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				state = pauseUntilResumed(ctx, state, pauseSignal, cancelChannel, resumeChannel, activities)
+				handled = true
+				return
+			}
+			if state.Status != shared.StatusPaused {
+				state = pause(ctx, state, pauseSignal, activities)
+			}
+			handled = true
 		})
+		selector.AddReceive(changePlanChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			var changePlanSignal ChangePlanSignal
+			ch.Receive(ctx, &changePlanSignal)
+			if workflow.GetVersion(ctx, changePlanChanges, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				return
+			}
+			state = changePlan(ctx, state, changePlanSignal, activities)
+			handled = true
+		})
+		selector.AddReceive(applyCouponChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			var applyCouponSignal ApplyCouponSignal
+			ch.Receive(ctx, &applyCouponSignal)
+			if workflow.GetVersion(ctx, changeCoupons, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				return
+			}
+			state = applyCoupon(ctx, state, applyCouponSignal, activities)
+			handled = true
+		})
+		selector.AddReceive(usageChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			var usageSignal UsageSignal
+			ch.Receive(ctx, &usageSignal)
+			if workflow.GetVersion(ctx, changeUsage, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				return
+			}
+			state.AddUsage(usageSignal)
+			handled = true
+		})
+		selector.AddReceive(refundChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			var refundSignal RefundSignal
+			ch.Receive(ctx, &refundSignal)
+			if workflow.GetVersion(ctx, changeRefunds, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				return
+			}
+			state = refund(ctx, state, refundSignal, activities)
+			handled = true
+		})
+		for !expired && !handled {
+			selector.Select(ctx)
+		}
+		cancelTimer()
+
+		if !expired {
+			continue
+		}
 
 		logger.Debug("subscription expired", "id", state.ID, "expires_at", state.ExpiresAt.String())
 
+		if state.CancelAtPeriodEnd {
+			err = workflow.ExecuteActivity(ctx, activities.FinalizeCancel, state).Get(ctx, &state)
+			if err != nil {
+				return state, err
+			}
+			break
+		}
 
-		err = workflow.ExecuteActivity(ctx, activities.Charge, state).Get(ctx, &state)
+		state, err = charge(ctx, state, 0, activities)
 
 		if err != nil {
-			if strings.Contains(err.Error(), ErrInsufficientFunds.Error()) {
+			if state.Status == shared.StatusTrialing {
+				logger.Error("subscription trial charge failed", "id", state.ID, "error", err.Error())
+				break
+			}
+			declined := IsDeclined(err)
+			// Runs from before only declines went to dunning took a refunded
+			// debit there too.
+			if errors.Is(err, ErrDebitRefunded) {
+				declined = workflow.GetVersion(ctx, changeDeclinesOnly, workflow.DefaultVersion, 1) == workflow.DefaultVersion
+			}
+			if declined {
+				if workflow.GetVersion(ctx, changeDunning, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+					break
+				}
+				var paid bool
+				state, paid = dunning(ctx, state, cancelChannel, retryPaymentChannel, activities)
+				if paid {
+					renewals++
+					continue
+				}
 				break
 			}
 			return state, err
 		}
 
-		for cancelSelector.HasPending() {
-			cancelSelector.Select(ctx)
-		}
+		renderReceipt(ctx, state, activities)
+		renewals++
 
-		if state.Canceled {
+		if cancelPending || (hasPendingSignals(ctx, cancelChannel) &&
+			workflow.GetVersion(ctx, changeImmediateCancel, workflow.DefaultVersion, 1) == workflow.DefaultVersion) {
+			state = cancelState(ctx, state)
 			break
 		}
 	}
@@ -72,7 +232,347 @@ func SubscriptionsWorkflow(ctx workflow.Context, state SubscriptionState, activi
 		}
 	}
 
+	upsertSearchAttributes(ctx, state)
+
 	logger.Debug("subscription workflow finished.", "id", state.ID)
 
 	return state, nil
-}
\ No newline at end of file
+}
+
+// hasPendingSignals reports whether a signal is waiting in any of channels, so
+// the run does not continue as new and drop it.
+func hasPendingSignals(ctx workflow.Context, channels ...workflow.ReceiveChannel) bool {
+	selector := workflow.NewSelector(ctx)
+	for _, ch := range channels {
+		selector.AddReceive(ch, func(workflow.ReceiveChannel, bool) {})
+	}
+	return selector.HasPending()
+}
+
+// charge pays for the next period of the subscription and then records it. When
+// the activation cannot be recorded the debit is refunded, so the user is never
+// charged for a period the subscription did not get, and the charge fails with
+// ErrDebitRefunded. Each attempt debits under its own idempotency key, as an
+// earlier one may be refunded.
+func charge(ctx workflow.Context, state SubscriptionState, attempt int, activities *Activities) (SubscriptionState, error) {
+	logger := workflow.GetLogger(ctx)
+
+	if workflow.GetVersion(ctx, changeChargeSaga, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		err := workflow.ExecuteActivity(ctx, activities.Charge, state).Get(ctx, &state)
+		return state, err
+	}
+
+	var debit types.SubscriptionDebitOutput
+	err := workflow.ExecuteActivity(ctx, activities.Debit, state, attempt).Get(ctx, &debit)
+	if err != nil {
+		return state, err
+	}
+
+	err = workflow.ExecuteActivity(ctx, activities.RecordActivation, state, debit).Get(ctx, &state)
+	if err == nil {
+		return state, nil
+	}
+
+	logger.Error("subscription activation failed, refunding debit", "id", state.ID, "activation", debit.Activation, "error", err.Error())
+
+	// The money has left the user already; keep retrying the refund until it
+	// goes through instead of giving up after the usual attempts.
+	compensationCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Second * 10,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumInterval: time.Minute * 10,
+		},
+	})
+	refundErr := workflow.ExecuteActivity(compensationCtx, activities.RefundDebit, state, debit).Get(ctx, nil)
+	if refundErr != nil {
+		logger.Error("subscription debit refund failed", "id", state.ID, "activation", debit.Activation, "error", refundErr.Error())
+		return state, err
+	}
+
+	logger.Debug("subscription debit refunded", "id", state.ID, "activation", debit.Activation)
+
+	return state, ErrDebitRefunded
+}
+
+// renderReceipt renders the receipt of a renewal. A failure is only logged:
+// the subscription is already charged and the invoice exists without it.
+func renderReceipt(ctx workflow.Context, state SubscriptionState, activities *Activities) {
+	logger := workflow.GetLogger(ctx)
+
+	if workflow.GetVersion(ctx, changeReceipts, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return
+	}
+
+	err := workflow.ExecuteActivity(ctx, activities.RenderReceipt, state).Get(ctx, nil)
+	if err != nil {
+		logger.Error("subscription receipt failed", "id", state.ID, "activation", state.Activations, "error", err.Error())
+		return
+	}
+
+	logger.Debug("subscription receipt rendered", "id", state.ID, "activation", state.Activations)
+}
+
+// upsertSearchAttributes indexes the fields of state that operators search
+// subscriptions by. Only the attributes that changed are upserted.
+func upsertSearchAttributes(ctx workflow.Context, state SubscriptionState) {
+	if workflow.GetVersion(ctx, changeSearchAttributes, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return
+	}
+
+	attributes := changedSearchAttributes(state, workflow.GetInfo(ctx).SearchAttributes)
+	if len(attributes) == 0 {
+		return
+	}
+
+	err := workflow.UpsertSearchAttributes(ctx, attributes)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("subscription search attributes failed", "id", state.ID, "error", err.Error())
+	}
+}
+
+func changePlan(ctx workflow.Context, state SubscriptionState, signal ChangePlanSignal, activities *Activities) SubscriptionState {
+	logger := workflow.GetLogger(ctx)
+
+	cmp, err := signal.Price.Cmp(state.Price)
+	if err != nil {
+		logger.Error("subscription plan change failed", "id", state.ID, "plan_id", signal.PlanID, "error", err.Error())
+		return state
+	}
+
+	req := types.ApplyPlanChangeRequest{
+		PlanID:      signal.PlanID,
+		PlanVersion: signal.PlanVersion,
+		Immediate:   cmp >= 0 || state.Status == shared.StatusTrialing,
+	}
+	if req.Immediate && state.Status != shared.StatusTrialing {
+		req.Proration, err = state.Prorate(signal.Price, workflow.Now(ctx))
+		if err != nil {
+			logger.Error("subscription plan change failed", "id", state.ID, "plan_id", signal.PlanID, "error", err.Error())
+			return state
+		}
+	}
+
+	changed := state
+	err = workflow.ExecuteActivity(ctx, activities.ApplyPlanChange, state, req).Get(ctx, &changed)
+	if err != nil {
+		logger.Error("subscription plan change failed", "id", state.ID, "plan_id", signal.PlanID, "error", err.Error())
+		return state
+	}
+
+	logger.Debug("subscription plan changed", "id", state.ID, "plan_id", signal.PlanID, "proration", req.Proration)
+
+	return changed
+}
+
+func refund(ctx workflow.Context, state SubscriptionState, signal RefundSignal, activities *Activities) SubscriptionState {
+	logger := workflow.GetLogger(ctx)
+
+	req := types.RefundRequest{
+		RefundID:  signal.RefundID,
+		InvoiceID: signal.InvoiceID,
+		Amount:    signal.Amount,
+		Reason:    signal.Reason,
+		Cancel:    signal.Cancel,
+	}
+
+	refunded := state
+	err := workflow.ExecuteActivity(ctx, activities.Refund, state, req).Get(ctx, &refunded)
+	if err != nil {
+		logger.Error("subscription refund failed", "id", state.ID, "invoice_id", signal.InvoiceID, "error", err.Error())
+		return state
+	}
+
+	logger.Debug("subscription refunded", "id", state.ID, "invoice_id", signal.InvoiceID, "canceled", refunded.Canceled)
+
+	return refunded
+}
+
+func applyCoupon(ctx workflow.Context, state SubscriptionState, signal ApplyCouponSignal, activities *Activities) SubscriptionState {
+	logger := workflow.GetLogger(ctx)
+
+	redeemed := state
+	err := workflow.ExecuteActivity(ctx, activities.RedeemCoupon, state, signal.Code).Get(ctx, &redeemed)
+	if err != nil {
+		logger.Error("subscription coupon failed", "id", state.ID, "code", signal.Code, "error", err.Error())
+		return state
+	}
+
+	logger.Debug("subscription coupon applied", "id", state.ID, "code", signal.Code)
+
+	return redeemed
+}
+
+func dunning(ctx workflow.Context, state SubscriptionState, cancelChannel, retryPaymentChannel workflow.ReceiveChannel, activities *Activities) (SubscriptionState, bool) {
+	logger := workflow.GetLogger(ctx)
+
+	var policy DunningPolicy
+	err := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+		return NewDunningPolicy()
+	}).Get(&policy)
+	if err != nil {
+		logger.Error("subscription dunning policy failed", "id", state.ID, "error", err.Error())
+		return state, false
+	}
+
+	pastDueSince := workflow.Now(ctx)
+	graceEndsAt := pastDueSince.Add(policy.GracePeriod)
+
+	err = workflow.ExecuteActivity(ctx, activities.MarkPastDue, state, graceEndsAt, pastDueSince).Get(ctx, &state)
+	if err != nil {
+		logger.Error("subscription past due failed", "id", state.ID, "error", err.Error())
+		return state, false
+	}
+
+	logger.Debug("subscription past due", "id", state.ID, "grace_ends_at", graceEndsAt.String())
+
+	upsertSearchAttributes(ctx, state)
+
+	// attempt indexes the schedule and only moves when a retry is due, while
+	// tries counts every charge, signaled retries included, so that none reuses
+	// the debit of an earlier one.
+	attempt, tries := 0, 0
+	for attempt < len(policy.Schedule) {
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(workflow.NewTimer(timerCtx, pastDueSince.Add(policy.Schedule[attempt]).Sub(workflow.Now(ctx))), func(f workflow.Future) {
+			attempt++
+		})
+		selector.AddReceive(cancelChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			state = receiveCancel(ctx, ch, state)
+		})
+		selector.AddReceive(retryPaymentChannel, func(ch workflow.ReceiveChannel, _ bool) {
+			ch.Receive(ctx, nil)
+		})
+		selector.Select(ctx)
+		cancelTimer()
+
+		if state.Canceled {
+			return state, false
+		}
+
+		tries++
+		logger.Debug("subscription payment retry", "id", state.ID, "attempt", tries)
+
+		state, err = charge(ctx, state, tries, activities)
+		if err == nil {
+			renderReceipt(ctx, state, activities)
+			return state, true
+		}
+
+		logger.Error("subscription payment retry failed", "id", state.ID, "attempt", tries, "error", err.Error())
+	}
+
+	return state, false
+}
+
+// pauseUntilResumed pauses the subscription and blocks until it resumes, and
+// only a cancel is handled meanwhile. Runs that paused before the main loop
+// waited out pauses itself still replay it.
+func pauseUntilResumed(ctx workflow.Context, state SubscriptionState, signal PauseSignal, cancelChannel, resumeChannel workflow.ReceiveChannel, activities *Activities) SubscriptionState {
+	logger := workflow.GetLogger(ctx)
+
+	err := workflow.ExecuteActivity(ctx, activities.MarkPaused, state, signal.ResumeAt, workflow.Now(ctx)).Get(ctx, &state)
+	if err != nil {
+		logger.Error("subscription pause failed", "id", state.ID, "error", err.Error())
+		return state
+	}
+
+	logger.Debug("subscription paused", "id", state.ID)
+
+	upsertSearchAttributes(ctx, state)
+
+	timerCtx, cancelTimer := workflow.WithCancel(ctx)
+	selector := workflow.NewSelector(ctx)
+	if signal.ResumeAt != nil {
+		selector.AddFuture(workflow.NewTimer(timerCtx, signal.ResumeAt.Sub(workflow.Now(ctx))), func(f workflow.Future) {})
+	}
+	selector.AddReceive(cancelChannel, func(ch workflow.ReceiveChannel, _ bool) {
+		state = receiveCancel(ctx, ch, state)
+	})
+	selector.AddReceive(resumeChannel, func(ch workflow.ReceiveChannel, _ bool) {
+		ch.Receive(ctx, nil)
+	})
+	selector.Select(ctx)
+	cancelTimer()
+
+	if state.Canceled {
+		return state
+	}
+
+	return resume(ctx, state, activities)
+}
+
+func pause(ctx workflow.Context, state SubscriptionState, signal PauseSignal, activities *Activities) SubscriptionState {
+	logger := workflow.GetLogger(ctx)
+
+	paused := state
+	err := workflow.ExecuteActivity(ctx, activities.MarkPaused, state, signal.ResumeAt, workflow.Now(ctx)).Get(ctx, &paused)
+	if err != nil {
+		logger.Error("subscription pause failed", "id", state.ID, "error", err.Error())
+		return state
+	}
+
+	logger.Debug("subscription paused", "id", state.ID, "resume_at", signal.ResumeAt)
+
+	return paused
+}
+
+// resume restarts the period of a paused subscription with the time it had
+// left. The subscription stays paused until that is recorded, so it retries
+// until it is.
+func resume(ctx workflow.Context, state SubscriptionState, activities *Activities) SubscriptionState {
+	logger := workflow.GetLogger(ctx)
+
+	resumedAt := workflow.Now(ctx)
+	resumed := state
+	for {
+		err := workflow.ExecuteActivity(ctx, activities.MarkResumed, state, resumedAt).Get(ctx, &resumed)
+		if err == nil {
+			break
+		}
+		logger.Error("subscription resume failed", "id", state.ID, "error", err.Error())
+		err = workflow.Sleep(ctx, time.Minute)
+		if err != nil {
+			return state
+		}
+	}
+
+	logger.Debug("subscription resumed", "id", state.ID, "expires_at", resumed.ExpiresAt.String())
+
+	return resumed
+}
+
+func setCancelAtPeriodEnd(ctx workflow.Context, state SubscriptionState, cancelAtPeriodEnd bool, activities *Activities) SubscriptionState {
+	logger := workflow.GetLogger(ctx)
+
+	if state.CancelAtPeriodEnd == cancelAtPeriodEnd {
+		return state
+	}
+
+	updated := state
+	err := workflow.ExecuteActivity(ctx, activities.SetCancelAtPeriodEnd, state, cancelAtPeriodEnd).Get(ctx, &updated)
+	if err != nil {
+		logger.Error("subscription cancel at period end failed", "id", state.ID, "error", err.Error())
+		return state
+	}
+
+	logger.Debug("subscription cancel at period end updated", "id", state.ID, "cancel_at_period_end", cancelAtPeriodEnd)
+
+	return updated
+}
+
+func receiveCancel(ctx workflow.Context, ch workflow.ReceiveChannel, state SubscriptionState) SubscriptionState {
+	var cancelSignal bool
+	ch.Receive(ctx, &cancelSignal)
+	return cancelState(ctx, state)
+}
+
+func cancelState(ctx workflow.Context, state SubscriptionState) SubscriptionState {
+	state.Status = shared.StatusCanceled
+	state.Canceled = true
+	state.CancelAtPeriodEnd = false
+	canceledAt := workflow.Now(ctx)
+	state.CanceledAt = &canceledAt
+	state.AccessEndsAt = &canceledAt
+	return state
+}
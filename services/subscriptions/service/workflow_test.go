@@ -0,0 +1,405 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/subscriptions/service"
+	"go-subscriptions-workflow/services/subscriptions/shared"
+	"go-subscriptions-workflow/types"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"sync"
+	"testing"
+	"time"
+)
+
+var startTime = time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+
+// activityCall is an activity the workflow started, at the time of the test
+// clock.
+type activityCall struct {
+	Name string
+	At   time.Time
+}
+
+type workflowTest struct {
+	*testsuite.TestWorkflowEnvironment
+	activities *service.Activities
+
+	mu    sync.Mutex
+	calls []activityCall
+}
+
+// newWorkflowTest registers activities without a service behind them, so each
+// test mocks the ones the workflow is expected to run and an unexpected one
+// fails the test.
+func newWorkflowTest(t *testing.T) *workflowTest {
+	var suite testsuite.WorkflowTestSuite
+	suite.SetLogger(testLogger{t})
+	test := &workflowTest{
+		TestWorkflowEnvironment: suite.NewTestWorkflowEnvironment(),
+		activities:              service.NewActivities(nil),
+	}
+	test.SetStartTime(startTime)
+	test.RegisterActivity(test.activities)
+	test.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		test.mu.Lock()
+		defer test.mu.Unlock()
+		test.calls = append(test.calls, activityCall{Name: info.ActivityType.Name, At: test.Now()})
+	})
+	t.Cleanup(func() {
+		test.AssertExpectations(t)
+	})
+	return test
+}
+
+func (test *workflowTest) run(state service.SubscriptionState) {
+	test.ExecuteWorkflow(service.SubscriptionsWorkflow, state, test.activities)
+}
+
+func (test *workflowTest) result(t *testing.T) service.SubscriptionState {
+	t.Helper()
+	if !test.IsWorkflowCompleted() {
+		t.Fatal("workflow not completed")
+	}
+	if err := test.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	var state service.SubscriptionState
+	if err := test.GetWorkflowResult(&state); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func (test *workflowTest) assertCalls(t *testing.T, want ...activityCall) {
+	t.Helper()
+	test.mu.Lock()
+	defer test.mu.Unlock()
+	if len(test.calls) != len(want) {
+		t.Fatalf("activities = %v, want %v", test.calls, want)
+	}
+	for index := range want {
+		if test.calls[index].Name != want[index].Name || !test.calls[index].At.Equal(want[index].At) {
+			t.Fatalf("activities = %v, want %v", test.calls, want)
+		}
+	}
+}
+
+func newSubscription() service.SubscriptionState {
+	return service.SubscriptionState{
+		ID:            "61800a1fe1b2c3d4e5f60718",
+		UserID:        "61800a0fe1b2c3d4e5f60717",
+		PlanID:        "61800a00e1b2c3d4e5f60716",
+		PlanVersion:   1,
+		Status:        shared.StatusActive,
+		Price:         money.New(1000, "USD"),
+		Interval:      "month",
+		IntervalCount: 1,
+		BillingAnchor: 1,
+		Activations:   1,
+		ActivatedAt:   startTime,
+		ExpiresAt:     startTime.AddDate(0, 1, 0),
+		CreatedAt:     startTime,
+		UpdatedAt:     startTime,
+	}
+}
+
+// debit stands in for a successful Debit of the next activation.
+func debit(ctx context.Context, state service.SubscriptionState, attempt int) (types.SubscriptionDebitOutput, error) {
+	paid := money.New(1000, "USD")
+	return types.SubscriptionDebitOutput{
+		ID:         state.ID,
+		UserID:     state.UserID,
+		Activation: state.Activations + 1,
+		Charge:     &types.ChargeOutput{Amount: paid, Paid: paid, PaymentID: "payment"},
+	}, nil
+}
+
+// renew stands in for a successful RecordActivation: it starts the next period
+// where the current one expires.
+func renew(ctx context.Context, state service.SubscriptionState, debit types.SubscriptionDebitOutput) (service.SubscriptionState, error) {
+	state.Status = shared.StatusActive
+	state.Activations++
+	state.ActivatedAt = state.ExpiresAt
+	state.ExpiresAt = state.ExpiresAt.AddDate(0, 1, 0)
+	return state, nil
+}
+
+// markPastDue stands in for MarkPastDue.
+func markPastDue(ctx context.Context, state service.SubscriptionState, graceEndsAt, pastDueSince time.Time) (service.SubscriptionState, error) {
+	state.Status = shared.StatusPastDue
+	state.PastDueSince = &pastDueSince
+	state.GraceEndsAt = &graceEndsAt
+	return state, nil
+}
+
+// disable stands in for Disable when the grace period ends.
+func disable(ctx context.Context, state service.SubscriptionState) (service.SubscriptionState, error) {
+	disabledAt := *state.GraceEndsAt
+	state.Status = shared.StatusDisabled
+	state.Disabled = true
+	state.DisabledAt = &disabledAt
+	return state, nil
+}
+
+func TestWorkflowRenewsEachPeriod(t *testing.T) {
+	test := newWorkflowTest(t)
+	test.OnActivity(test.activities.Debit, mock.Anything, mock.Anything, 0).Return(debit).Times(2)
+	test.OnActivity(test.activities.RecordActivation, mock.Anything, mock.Anything, mock.Anything).Return(renew).Times(2)
+	test.OnActivity(test.activities.RenderReceipt, mock.Anything, mock.Anything).Return(nil).Times(2)
+	test.RegisterDelayedCallback(func() {
+		test.SignalWorkflow(service.SignalCancelSubscription, true)
+	}, time.Hour*24*75)
+
+	test.run(newSubscription())
+
+	state := test.result(t)
+	if !state.Canceled || state.Activations != 3 {
+		t.Fatalf("state canceled=%v activations=%v, want canceled after 3 activations", state.Canceled, state.Activations)
+	}
+	firstRenewal := startTime.AddDate(0, 1, 0)
+	secondRenewal := startTime.AddDate(0, 2, 0)
+	test.assertCalls(t,
+		activityCall{Name: "Debit", At: firstRenewal},
+		activityCall{Name: "RecordActivation", At: firstRenewal},
+		activityCall{Name: "RenderReceipt", At: firstRenewal},
+		activityCall{Name: "Debit", At: secondRenewal},
+		activityCall{Name: "RecordActivation", At: secondRenewal},
+		activityCall{Name: "RenderReceipt", At: secondRenewal},
+	)
+}
+
+func TestWorkflowDisablesAfterInsufficientFunds(t *testing.T) {
+	test := newWorkflowTest(t)
+	expiresAt := startTime.AddDate(0, 1, 0)
+	for attempt := 0; attempt < 4; attempt++ {
+		test.OnActivity(test.activities.Debit, mock.Anything, mock.Anything, attempt).Return(types.SubscriptionDebitOutput{}, service.ErrInsufficientFunds).Once()
+	}
+	test.OnActivity(test.activities.MarkPastDue, mock.Anything, mock.Anything, mock.Anything, expiresAt).Return(markPastDue).Once()
+	test.OnActivity(test.activities.Disable, mock.Anything, mock.Anything).Return(disable).Once()
+
+	test.run(newSubscription())
+
+	state := test.result(t)
+	if !state.Disabled || state.Status != shared.StatusDisabled {
+		t.Fatalf("state status=%v disabled=%v, want disabled", state.Status, state.Disabled)
+	}
+	schedule := shared.DunningSchedule()
+	test.assertCalls(t,
+		activityCall{Name: "Debit", At: expiresAt},
+		activityCall{Name: "MarkPastDue", At: expiresAt},
+		activityCall{Name: "Debit", At: expiresAt.Add(schedule[0])},
+		activityCall{Name: "Debit", At: expiresAt.Add(schedule[1])},
+		activityCall{Name: "Debit", At: expiresAt.Add(schedule[2])},
+		activityCall{Name: "Disable", At: expiresAt.Add(schedule[2])},
+	)
+}
+
+func TestWorkflowRetriesPaymentOnSignal(t *testing.T) {
+	test := newWorkflowTest(t)
+	// The signaled retry takes its own attempt and the schedule carries on
+	// after it, so no two debits share an idempotency key.
+	for attempt := 0; attempt < 5; attempt++ {
+		test.OnActivity(test.activities.Debit, mock.Anything, mock.Anything, attempt).Return(types.SubscriptionDebitOutput{}, service.ErrInsufficientFunds).Once()
+	}
+	test.OnActivity(test.activities.MarkPastDue, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(markPastDue).Once()
+	test.OnActivity(test.activities.Disable, mock.Anything, mock.Anything).Return(disable).Once()
+	expiresAt := startTime.AddDate(0, 1, 0)
+	retriedAt := expiresAt.Add(time.Hour * 12)
+	test.RegisterDelayedCallback(func() {
+		test.SignalWorkflow(service.SignalRetryPayment, nil)
+	}, retriedAt.Sub(startTime))
+
+	test.run(newSubscription())
+
+	state := test.result(t)
+	if !state.Disabled || state.Status != shared.StatusDisabled {
+		t.Fatalf("state status=%v disabled=%v, want disabled", state.Status, state.Disabled)
+	}
+	schedule := shared.DunningSchedule()
+	test.assertCalls(t,
+		activityCall{Name: "Debit", At: expiresAt},
+		activityCall{Name: "MarkPastDue", At: expiresAt},
+		activityCall{Name: "Debit", At: retriedAt},
+		activityCall{Name: "Debit", At: expiresAt.Add(schedule[0])},
+		activityCall{Name: "Debit", At: expiresAt.Add(schedule[1])},
+		activityCall{Name: "Debit", At: expiresAt.Add(schedule[2])},
+		activityCall{Name: "Disable", At: expiresAt.Add(schedule[2])},
+	)
+}
+
+func TestWorkflowCancelMidPeriod(t *testing.T) {
+	test := newWorkflowTest(t)
+	canceledAt := startTime.Add(time.Hour * 24 * 10)
+	test.RegisterDelayedCallback(func() {
+		test.SignalWorkflow(service.SignalCancelSubscription, true)
+	}, canceledAt.Sub(startTime))
+
+	test.run(newSubscription())
+
+	state := test.result(t)
+	if !state.Canceled || state.Status != shared.StatusCanceled {
+		t.Fatalf("state status=%v canceled=%v, want canceled", state.Status, state.Canceled)
+	}
+	if state.CanceledAt == nil || !state.CanceledAt.Equal(canceledAt) {
+		t.Fatalf("state canceled_at=%v, want %v", state.CanceledAt, canceledAt)
+	}
+	if state.AccessEndsAt == nil || !state.AccessEndsAt.Equal(canceledAt) {
+		t.Fatalf("state access_ends_at=%v, want %v", state.AccessEndsAt, canceledAt)
+	}
+	test.assertCalls(t)
+}
+
+// markPaused stands in for MarkPaused.
+func markPaused(ctx context.Context, state service.SubscriptionState, resumeAt *time.Time, pausedAt time.Time) (service.SubscriptionState, error) {
+	state.Status = shared.StatusPaused
+	state.PausedAt = &pausedAt
+	state.ResumeAt = resumeAt
+	return state, nil
+}
+
+// markResumed stands in for MarkResumed: the period restarts with the time it
+// had left.
+func markResumed(ctx context.Context, state service.SubscriptionState, resumedAt time.Time) (service.SubscriptionState, error) {
+	state.Status = shared.StatusActive
+	state.ExpiresAt = resumedAt.Add(state.ExpiresAt.Sub(*state.PausedAt))
+	state.PausedAt = nil
+	state.ResumeAt = nil
+	return state, nil
+}
+
+func TestWorkflowCancelWhilePaused(t *testing.T) {
+	test := newWorkflowTest(t)
+	pausedAt := startTime.Add(time.Hour * 24 * 5)
+	canceledAt := startTime.Add(time.Hour * 24 * 10)
+	// Signals are still handled while paused, not once the subscription resumes.
+	refundedAt := startTime.Add(time.Hour * 24 * 7)
+	test.OnActivity(test.activities.MarkPaused, mock.Anything, mock.Anything, (*time.Time)(nil), pausedAt).Return(markPaused).Once()
+	test.OnActivity(test.activities.Refund, mock.Anything, mock.Anything, types.RefundRequest{InvoiceID: "invoice"}).Return(
+		func(ctx context.Context, state service.SubscriptionState, req types.RefundRequest) (service.SubscriptionState, error) {
+			return state, nil
+		}).Once()
+	test.RegisterDelayedCallback(func() {
+		test.SignalWorkflow(service.SignalPauseSubscription, service.PauseSignal{})
+	}, pausedAt.Sub(startTime))
+	test.RegisterDelayedCallback(func() {
+		test.SignalWorkflow(service.SignalRefund, service.RefundSignal{InvoiceID: "invoice"})
+	}, refundedAt.Sub(startTime))
+	test.RegisterDelayedCallback(func() {
+		test.SignalWorkflow(service.SignalCancelSubscription, true)
+	}, canceledAt.Sub(startTime))
+
+	test.run(newSubscription())
+
+	state := test.result(t)
+	if !state.Canceled || state.CanceledAt == nil || !state.CanceledAt.Equal(canceledAt) {
+		t.Fatalf("state canceled=%v canceled_at=%v, want canceled at %v", state.Canceled, state.CanceledAt, canceledAt)
+	}
+	test.assertCalls(t,
+		activityCall{Name: "MarkPaused", At: pausedAt},
+		activityCall{Name: "Refund", At: refundedAt},
+	)
+}
+
+func TestWorkflowResumesOnDate(t *testing.T) {
+	test := newWorkflowTest(t)
+	pausedAt := startTime.Add(time.Hour * 24 * 5)
+	resumeAt := startTime.Add(time.Hour * 24 * 8)
+	// The usage recorded while paused is kept and does not resume the period.
+	usageAt := startTime.Add(time.Hour * 24 * 6)
+	renewedAt := startTime.AddDate(0, 1, 0).Add(resumeAt.Sub(pausedAt))
+	test.OnActivity(test.activities.MarkPaused, mock.Anything, mock.Anything, &resumeAt, pausedAt).Return(markPaused).Once()
+	test.OnActivity(test.activities.MarkResumed, mock.Anything, mock.Anything, resumeAt).Return(markResumed).Once()
+	test.OnActivity(test.activities.Debit, mock.Anything, mock.Anything, 0).Return(debit).Once()
+	test.OnActivity(test.activities.RecordActivation, mock.Anything, mock.Anything, mock.Anything).Return(renew).Once()
+	test.OnActivity(test.activities.RenderReceipt, mock.Anything, mock.Anything).Return(nil).Once()
+	test.RegisterDelayedCallback(func() {
+		test.SignalWorkflow(service.SignalPauseSubscription, service.PauseSignal{ResumeAt: &resumeAt})
+	}, pausedAt.Sub(startTime))
+	test.RegisterDelayedCallback(func() {
+		test.SignalWorkflow(service.SignalRecordUsage, service.UsageSignal{Feature: "downloads", Quantity: 3})
+	}, usageAt.Sub(startTime))
+	test.RegisterDelayedCallback(func() {
+		test.SignalWorkflow(service.SignalCancelSubscription, true)
+	}, renewedAt.Add(time.Hour).Sub(startTime))
+
+	test.run(newSubscription())
+
+	state := test.result(t)
+	if !state.Canceled || state.Activations != 2 {
+		t.Fatalf("state canceled=%v activations=%v, want canceled after 2 activations", state.Canceled, state.Activations)
+	}
+	test.assertCalls(t,
+		activityCall{Name: "MarkPaused", At: pausedAt},
+		activityCall{Name: "MarkResumed", At: resumeAt},
+		activityCall{Name: "Debit", At: renewedAt},
+		activityCall{Name: "RecordActivation", At: renewedAt},
+		activityCall{Name: "RenderReceipt", At: renewedAt},
+	)
+}
+
+func TestWorkflowFailsWhenChargeRetriesRunOut(t *testing.T) {
+	test := newWorkflowTest(t)
+	test.OnActivity(test.activities.Debit, mock.Anything, mock.Anything, mock.Anything).Return(types.SubscriptionDebitOutput{}, errors.New("database unavailable")).Times(3)
+
+	test.run(newSubscription())
+
+	if !test.IsWorkflowCompleted() {
+		t.Fatal("workflow not completed")
+	}
+	var applicationErr *temporal.ApplicationError
+	if err := test.GetWorkflowError(); !errors.As(err, &applicationErr) || applicationErr.Error() != "database unavailable" {
+		t.Fatalf("workflow error = %v, want the charge error", err)
+	}
+	test.mu.Lock()
+	defer test.mu.Unlock()
+	if len(test.calls) != 3 {
+		t.Fatalf("activities = %v, want 3 debit attempts", test.calls)
+	}
+	for _, call := range test.calls {
+		if call.Name != "Debit" {
+			t.Fatalf("activities = %v, want only charge attempts", test.calls)
+		}
+	}
+}
+
+func TestWorkflowRefundsDebitWhenActivationFails(t *testing.T) {
+	test := newWorkflowTest(t)
+	test.OnActivity(test.activities.Debit, mock.Anything, mock.Anything, 0).Return(debit).Once()
+	test.OnActivity(test.activities.RecordActivation, mock.Anything, mock.Anything, mock.Anything).Return(service.SubscriptionState{}, errors.New("database unavailable")).Times(3)
+	test.OnActivity(test.activities.RefundDebit, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, state service.SubscriptionState, debit types.SubscriptionDebitOutput) error {
+			if debit.Activation != 2 || debit.Charge.PaymentID != "payment" {
+				return temporal.NewNonRetryableApplicationError("unexpected debit", "test", nil)
+			}
+			return nil
+		}).Once()
+
+	test.run(newSubscription())
+
+	if !test.IsWorkflowCompleted() {
+		t.Fatal("workflow not completed")
+	}
+	// Nothing was declined, so the refunded charge fails the run instead of
+	// sending the subscription to dunning.
+	var applicationErr *temporal.ApplicationError
+	if err := test.GetWorkflowError(); !errors.As(err, &applicationErr) || applicationErr.Error() != service.ErrDebitRefunded.Error() {
+		t.Fatalf("workflow error = %v, want %v", err, service.ErrDebitRefunded)
+	}
+	want := []string{"Debit", "RecordActivation", "RecordActivation", "RecordActivation", "RefundDebit"}
+	test.mu.Lock()
+	defer test.mu.Unlock()
+	if len(test.calls) != len(want) {
+		t.Fatalf("activities = %v, want %v", test.calls, want)
+	}
+	for index, call := range test.calls {
+		if call.Name != want[index] {
+			t.Fatalf("activities = %v, want %v", test.calls, want)
+		}
+	}
+}
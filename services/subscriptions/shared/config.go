@@ -0,0 +1,61 @@
+package shared
+
+import (
+	"go-subscriptions-workflow/util"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	dunningSchedule         = []time.Duration{time.Hour * 24, time.Hour * 24 * 3, time.Hour * 24 * 7}
+	gracePeriod             = time.Hour * 24 * 7
+	continueAsNewRenewals   = 12
+	continueAsNewIterations = 500
+)
+
+func LoadConfigFromEnv() {
+	if value := os.Getenv("SUBSCRIPTIONS_DUNNING_SCHEDULE"); value != "" {
+		schedule := make([]time.Duration, 0)
+		for _, item := range strings.Split(value, ",") {
+			delay, err := time.ParseDuration(strings.TrimSpace(item))
+			util.PanicOnError(err)
+			schedule = append(schedule, delay)
+		}
+		dunningSchedule = schedule
+	}
+	if value := os.Getenv("SUBSCRIPTIONS_GRACE_PERIOD"); value != "" {
+		var err error
+		gracePeriod, err = time.ParseDuration(value)
+		util.PanicOnError(err)
+	}
+	if value := os.Getenv("SUBSCRIPTIONS_CONTINUE_AS_NEW_RENEWALS"); value != "" {
+		var err error
+		continueAsNewRenewals, err = strconv.Atoi(value)
+		util.PanicOnError(err)
+	}
+	if value := os.Getenv("SUBSCRIPTIONS_CONTINUE_AS_NEW_ITERATIONS"); value != "" {
+		var err error
+		continueAsNewIterations, err = strconv.Atoi(value)
+		util.PanicOnError(err)
+	}
+}
+
+func DunningSchedule() []time.Duration {
+	schedule := make([]time.Duration, len(dunningSchedule))
+	copy(schedule, dunningSchedule)
+	return schedule
+}
+
+func GracePeriod() time.Duration {
+	return gracePeriod
+}
+
+func ContinueAsNewRenewals() int {
+	return continueAsNewRenewals
+}
+
+func ContinueAsNewIterations() int {
+	return continueAsNewIterations
+}
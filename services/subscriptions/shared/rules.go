@@ -2,12 +2,6 @@ package shared
 
 import (
 	"errors"
-	"time"
-)
-
-const (
-	DefaultPrice      = 50.0
-	DefaultExpiration = time.Second * 20
 )
 
 var (
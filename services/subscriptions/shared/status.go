@@ -0,0 +1,10 @@
+package shared
+
+const (
+	StatusTrialing = "trialing"
+	StatusActive   = "active"
+	StatusPastDue  = "past_due"
+	StatusPaused   = "paused"
+	StatusCanceled = "canceled"
+	StatusDisabled = "disabled"
+)
@@ -39,16 +39,34 @@ func (s *subscriptionsStore) Update(ctx context.Context, subscription *models.Su
 
 	update := bson.M{
 		"$set": bson.M{
-			"price":        subscription.Price,
-			"features":     subscription.Features,
-			"activations":  subscription.Activations,
-			"activated_at": subscription.ActivatedAt,
-			"expires_at":   subscription.ExpiresAt,
-			"canceled":     subscription.Canceled,
-			"canceled_at":  subscription.CanceledAt,
-			"disabled":     subscription.Disabled,
-			"disabled_at":  subscription.DisabledAt,
-			"updated_at":   subscription.UpdatedAt,
+			"plan_id":              subscription.PlanID,
+			"plan_version":         subscription.PlanVersion,
+			"pending_plan_id":      subscription.PendingPlanID,
+			"pending_plan_version": subscription.PendingPlanVersion,
+			"status":               subscription.Status,
+			"price":                subscription.Price,
+			"interval":             subscription.Interval,
+			"interval_count":       subscription.IntervalCount,
+			"billing_anchor":       subscription.BillingAnchor,
+			"features":             subscription.Features,
+			"metered_prices":       subscription.MeteredPrices,
+			"activations":          subscription.Activations,
+			"trial_ends_at":        subscription.TrialEndsAt,
+			"past_due_since":       subscription.PastDueSince,
+			"grace_ends_at":        subscription.GraceEndsAt,
+			"paused_at":            subscription.PausedAt,
+			"resume_at":            subscription.ResumeAt,
+			"pauses":               subscription.Pauses,
+			"discount":             subscription.Discount,
+			"last_charge":          subscription.LastCharge,
+			"activated_at":         subscription.ActivatedAt,
+			"expires_at":           subscription.ExpiresAt,
+			"canceled":             subscription.Canceled,
+			"canceled_at":          subscription.CanceledAt,
+			"cancel_at_period_end": subscription.CancelAtPeriodEnd,
+			"disabled":             subscription.Disabled,
+			"disabled_at":          subscription.DisabledAt,
+			"updated_at":           subscription.UpdatedAt,
 		},
 	}
 
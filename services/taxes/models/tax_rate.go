@@ -0,0 +1,35 @@
+package models
+
+import (
+	"go-subscriptions-workflow/types"
+	"time"
+)
+
+type TaxRate struct {
+	ID        string    `bson:"_id"`
+	Country   string    `bson:"country"`
+	Region    string    `bson:"region"`
+	Name      string    `bson:"name"`
+	Percent   float64   `bson:"percent"`
+	Inclusive bool      `bson:"inclusive"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+func NewID(country, region string) string {
+	if region == "" {
+		return country
+	}
+	return country + "-" + region
+}
+
+func (r *TaxRate) Out() *types.TaxRateOutput {
+	return &types.TaxRateOutput{
+		ID:        r.ID,
+		Country:   r.Country,
+		Region:    r.Region,
+		Name:      r.Name,
+		Percent:   r.Percent,
+		Inclusive: r.Inclusive,
+		UpdatedAt: r.UpdatedAt,
+	}
+}
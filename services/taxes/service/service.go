@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/money"
+	"go-subscriptions-workflow/services/taxes/models"
+	"go-subscriptions-workflow/services/taxes/store"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type TaxesService interface {
+	SetRate(ctx context.Context, in *types.SetTaxRateInput) (*types.TaxRateOutput, error)
+	GetRates(ctx context.Context) ([]*types.TaxRateOutput, error)
+	Calculate(ctx context.Context, in *types.CalculateTaxInput) (*types.TaxOutput, error)
+}
+
+type taxesService struct {
+	taxRatesStore store.TaxRatesStore
+}
+
+func NewTaxesService(dbConn db.Connection) TaxesService {
+	return &taxesService{taxRatesStore: store.NewTaxRatesStore(dbConn.DB())}
+}
+
+func (s *taxesService) SetRate(ctx context.Context, in *types.SetTaxRateInput) (*types.TaxRateOutput, error) {
+	if in.Country == "" || in.Percent <= 0 || in.Percent > 100 {
+		return nil, fmt.Errorf("invalid tax rate: country=%v, region=%v, percent=%v", in.Country, in.Region, in.Percent)
+	}
+	rate := &models.TaxRate{
+		ID:        models.NewID(in.Country, in.Region),
+		Country:   in.Country,
+		Region:    in.Region,
+		Name:      in.Name,
+		Percent:   in.Percent,
+		Inclusive: in.Inclusive,
+		UpdatedAt: time.Now(),
+	}
+	err := s.taxRatesStore.Upsert(ctx, rate)
+	if err != nil {
+		return nil, err
+	}
+	return rate.Out(), nil
+}
+
+func (s *taxesService) GetRates(ctx context.Context) ([]*types.TaxRateOutput, error) {
+	rates, err := s.taxRatesStore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.TaxRateOutput, 0, len(rates))
+	for index := range rates {
+		out = append(out, rates[index].Out())
+	}
+	return out, nil
+}
+
+// Calculate applies the rate of the region, falling back to the country. With
+// no rate configured, or for a tax exempt customer, the amount is untaxed.
+// Inclusive rates take the tax out of the amount; exclusive ones add it on top.
+func (s *taxesService) Calculate(ctx context.Context, in *types.CalculateTaxInput) (*types.TaxOutput, error) {
+	out := &types.TaxOutput{Net: in.Amount, Tax: money.Zero(in.Amount.Currency), Gross: in.Amount}
+	if in.TaxExempt || in.Country == "" {
+		return out, nil
+	}
+	rate, err := s.getRate(ctx, in.Country, in.Region)
+	if err == mongo.ErrNoDocuments {
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	out.Name = rate.Name
+	out.Percent = rate.Percent
+	out.Inclusive = rate.Inclusive
+	if rate.Inclusive {
+		out.Net = in.Amount.Ratio(100 / (100 + rate.Percent))
+		out.Tax, err = in.Amount.Sub(out.Net)
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	out.Tax = in.Amount.Percent(rate.Percent)
+	out.Gross, err = in.Amount.Add(out.Tax)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *taxesService) getRate(ctx context.Context, country, region string) (*models.TaxRate, error) {
+	if region != "" {
+		rate, err := s.taxRatesStore.Get(ctx, models.NewID(country, region))
+		if err != mongo.ErrNoDocuments {
+			return rate, err
+		}
+	}
+	return s.taxRatesStore.Get(ctx, models.NewID(country, ""))
+}
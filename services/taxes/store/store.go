@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"go-subscriptions-workflow/services/taxes/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+)
+
+type TaxRatesStore interface {
+	Upsert(ctx context.Context, rate *models.TaxRate) error
+	Get(ctx context.Context, id string) (*models.TaxRate, error)
+	GetAll(ctx context.Context) ([]*models.TaxRate, error)
+}
+
+type taxRatesStore struct {
+	coll *mongo.Collection
+}
+
+func NewTaxRatesStore(dbConn *mongo.Database) TaxRatesStore {
+	return &taxRatesStore{coll: dbConn.Collection("tax_rates")}
+}
+
+func (s *taxRatesStore) Upsert(ctx context.Context, rate *models.TaxRate) error {
+	result, err := s.coll.ReplaceOne(ctx, bson.M{"_id": rate.ID}, rate, options.Replace().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	log.Printf("tax rate upserted: %+v\n", result)
+	return nil
+}
+
+func (s *taxRatesStore) Get(ctx context.Context, id string) (*models.TaxRate, error) {
+	var rate models.TaxRate
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&rate)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (s *taxRatesStore) GetAll(ctx context.Context) ([]*models.TaxRate, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var rates []*models.TaxRate
+	err = cursor.All(ctx, &rates)
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
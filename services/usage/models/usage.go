@@ -0,0 +1,41 @@
+package models
+
+import (
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type UsageRecord struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	SubscriptionID primitive.ObjectID `bson:"subscription_id"`
+	UserID         primitive.ObjectID `bson:"user_id"`
+	Feature        string             `bson:"feature"`
+	Quantity       int64              `bson:"quantity"`
+	Activation     int                `bson:"activation"`
+	RecordedAt     time.Time          `bson:"recorded_at"`
+}
+
+func (r *UsageRecord) Out() *types.UsageRecordOutput {
+	return &types.UsageRecordOutput{
+		ID:             r.ID.Hex(),
+		SubscriptionID: r.SubscriptionID.Hex(),
+		UserID:         r.UserID.Hex(),
+		Feature:        r.Feature,
+		Quantity:       r.Quantity,
+		Activation:     r.Activation,
+		RecordedAt:     r.RecordedAt,
+	}
+}
+
+type UsageTotal struct {
+	Feature  string `bson:"_id"`
+	Quantity int64  `bson:"quantity"`
+}
+
+func (t *UsageTotal) Out() *types.UsageOutput {
+	return &types.UsageOutput{
+		Feature:  t.Feature,
+		Quantity: t.Quantity,
+	}
+}
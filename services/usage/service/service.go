@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/services/usage/models"
+	"go-subscriptions-workflow/services/usage/store"
+	"go-subscriptions-workflow/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type UsageService interface {
+	RecordUsage(ctx context.Context, in *types.RecordUsageInput) (*types.UsageRecordOutput, error)
+	GetUsage(ctx context.Context, subscriptionID string, activation int) ([]*types.UsageOutput, error)
+}
+
+type usageService struct {
+	usageStore store.UsageStore
+}
+
+func NewUsageService(dbConn db.Connection) UsageService {
+	return &usageService{usageStore: store.NewUsageStore(dbConn.DB())}
+}
+
+func (s *usageService) RecordUsage(ctx context.Context, in *types.RecordUsageInput) (*types.UsageRecordOutput, error) {
+	subscriptionID, err := primitive.ObjectIDFromHex(in.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := primitive.ObjectIDFromHex(in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	record := &models.UsageRecord{
+		ID:             primitive.NewObjectID(),
+		SubscriptionID: subscriptionID,
+		UserID:         userID,
+		Feature:        in.Feature,
+		Quantity:       in.Quantity,
+		Activation:     in.Activation,
+		RecordedAt:     time.Now(),
+	}
+	err = s.usageStore.Create(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	return record.Out(), nil
+}
+
+func (s *usageService) GetUsage(ctx context.Context, subscriptionID string, activation int) ([]*types.UsageOutput, error) {
+	id, err := primitive.ObjectIDFromHex(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.usageStore.GetTotals(ctx, id, activation)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.UsageOutput, 0, len(totals))
+	for index := range totals {
+		out = append(out, totals[index].Out())
+	}
+	return out, nil
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"go-subscriptions-workflow/services/usage/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+)
+
+type UsageStore interface {
+	Create(ctx context.Context, record *models.UsageRecord) error
+	GetTotals(ctx context.Context, subscriptionID primitive.ObjectID, activation int) ([]*models.UsageTotal, error)
+}
+
+type usageStore struct {
+	coll *mongo.Collection
+}
+
+func NewUsageStore(dbConn *mongo.Database) UsageStore {
+	return &usageStore{coll: dbConn.Collection("usage_records")}
+}
+
+func (s *usageStore) Create(ctx context.Context, record *models.UsageRecord) error {
+	result, err := s.coll.InsertOne(ctx, record)
+	if err != nil {
+		return err
+	}
+	log.Printf("usage record created: %+v\n", result)
+	return nil
+}
+
+func (s *usageStore) GetTotals(ctx context.Context, subscriptionID primitive.ObjectID, activation int) ([]*models.UsageTotal, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"subscription_id": subscriptionID, "activation": activation}}},
+		{{Key: "$group", Value: bson.M{"_id": "$feature", "quantity": bson.M{"$sum": "$quantity"}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	cursor, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var totals []*models.UsageTotal
+	err = cursor.All(ctx, &totals)
+	if err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
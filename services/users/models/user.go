@@ -1,27 +1,124 @@
 package models
 
 import (
+	"go-subscriptions-workflow/money"
 	"go-subscriptions-workflow/types"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
 	"time"
 )
 
 type User struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Email          string             `bson:"email"`
+	Password       string             `bson:"password"`
+	Currency       string             `bson:"currency"`
+	Balances       []money.Money      `bson:"balances"`
+	BillingAddress *Address           `bson:"billing_address"`
+	TaxExempt      bool               `bson:"tax_exempt"`
+	PaymentMethods []*PaymentMethod   `bson:"payment_methods"`
+	TrialPlanIDs   []primitive.ObjectID `bson:"trial_plan_ids"`
+	CreatedAt      time.Time          `bson:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at"`
+}
+
+type Address struct {
+	Line1      string `bson:"line1"`
+	Line2      string `bson:"line2"`
+	City       string `bson:"city"`
+	Region     string `bson:"region"`
+	PostalCode string `bson:"postal_code"`
+	Country    string `bson:"country"`
+}
+
+func (a *Address) Out() *types.Address {
+	return &types.Address{
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		Region:     a.Region,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
+
+type PaymentMethod struct {
 	ID        primitive.ObjectID `bson:"_id"`
-	Email     string             `bson:"email"`
-	Password  string             `bson:"password"`
-	Balance   float64            `bson:"balance"`
+	Gateway   string             `bson:"gateway"`
+	Token     string             `bson:"token"`
+	Priority  int                `bson:"priority"`
 	CreatedAt time.Time          `bson:"created_at"`
 	UpdatedAt time.Time          `bson:"updated_at"`
 }
 
+func (p *PaymentMethod) Out() *types.PaymentMethodOutput {
+	return &types.PaymentMethodOutput{
+		ID:        p.ID.Hex(),
+		Gateway:   p.Gateway,
+		Token:     p.Token,
+		Priority:  p.Priority,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+func (u *User) PaymentMethod(id primitive.ObjectID) *PaymentMethod {
+	for index := range u.PaymentMethods {
+		if u.PaymentMethods[index].ID == id {
+			return u.PaymentMethods[index]
+		}
+	}
+	return nil
+}
+
+// SortPaymentMethods orders the methods by priority, lowest first, keeping
+// the order they were added in for equal priorities.
+func (u *User) SortPaymentMethods() {
+	sort.SliceStable(u.PaymentMethods, func(i, j int) bool {
+		return u.PaymentMethods[i].Priority < u.PaymentMethods[j].Priority
+	})
+}
+
+func (u *User) Balance(currency string) money.Money {
+	for index := range u.Balances {
+		if u.Balances[index].Currency == currency {
+			return u.Balances[index]
+		}
+	}
+	return money.Zero(currency)
+}
+
+func (u *User) SetBalance(balance money.Money) {
+	for index := range u.Balances {
+		if u.Balances[index].Currency == balance.Currency {
+			u.Balances[index] = balance
+			return
+		}
+	}
+	u.Balances = append(u.Balances, balance)
+}
+
 func (u *User) Out() *types.UserOutput {
-	return &types.UserOutput{
-		ID:        u.ID.Hex(),
-		Email:     u.Email,
-		Password:  u.Password,
-		Balance:   u.Balance,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+	out := &types.UserOutput{
+		ID:          u.ID.Hex(),
+		Email:       u.Email,
+		Password:    u.Password,
+		Currency:    u.Currency,
+		TaxExempt:   u.TaxExempt,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+	}
+	if u.BillingAddress != nil {
+		out.BillingAddress = u.BillingAddress.Out()
+	}
+	out.PaymentMethods = make([]*types.PaymentMethodOutput, 0, len(u.PaymentMethods))
+	for index := range u.PaymentMethods {
+		out.PaymentMethods = append(out.PaymentMethods, u.PaymentMethods[index].Out())
+	}
+	out.Balances = make([]money.Money, 0, len(u.Balances))
+	out.Balances = append(out.Balances, u.Balances...)
+	for index := range u.TrialPlanIDs {
+		out.TrialPlanIDs = append(out.TrialPlanIDs, u.TrialPlanIDs[index].Hex())
 	}
+	return out
 }
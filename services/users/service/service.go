@@ -4,13 +4,19 @@ import (
 	"context"
 	"fmt"
 	"go-subscriptions-workflow/db"
+	"go-subscriptions-workflow/money"
 	"go-subscriptions-workflow/security/passwords"
 	"go-subscriptions-workflow/security/tokens"
+	ledgersvc "go-subscriptions-workflow/services/ledger/service"
+	ledgershared "go-subscriptions-workflow/services/ledger/shared"
+	paymentsshared "go-subscriptions-workflow/services/payments/shared"
 	"go-subscriptions-workflow/services/users/models"
+	"go-subscriptions-workflow/services/users/shared"
 	"go-subscriptions-workflow/services/users/store"
 	"go-subscriptions-workflow/types"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"log"
 	"time"
 )
 
@@ -20,18 +26,40 @@ type UsersService interface {
 	GetUser(ctx context.Context, id string) (*types.UserOutput, error)
 	Credit(ctx context.Context, in *types.CreditInput) (*types.UserOutput, error)
 	Debit(ctx context.Context, in *types.DebitInput) (*types.UserOutput, error)
+	SetCurrency(ctx context.Context, in *types.SetCurrencyInput) (*types.UserOutput, error)
+	SetBillingAddress(ctx context.Context, in *types.SetBillingAddressInput) (*types.UserOutput, error)
+	SetTaxExempt(ctx context.Context, in *types.SetTaxExemptInput) (*types.UserOutput, error)
+	GetPaymentMethods(ctx context.Context, id string) ([]*types.PaymentMethodOutput, error)
+	AddPaymentMethod(ctx context.Context, in *types.AddPaymentMethodInput) (*types.PaymentMethodOutput, error)
+	UpdatePaymentMethod(ctx context.Context, in *types.UpdatePaymentMethodInput) (*types.PaymentMethodOutput, error)
+	DeletePaymentMethod(ctx context.Context, in *types.DeletePaymentMethodInput) ([]*types.PaymentMethodOutput, error)
+	GetTransactions(ctx context.Context, id string) ([]*types.LedgerEntryOutput, error)
+	UseTrial(ctx context.Context, id, planID string) (*types.UserOutput, error)
+	ReleaseTrial(ctx context.Context, id, planID string) (*types.UserOutput, error)
 }
 
 type usersService struct {
-	usersStore store.UsersStore
+	usersStore    store.UsersStore
+	ledgerService ledgersvc.LedgerService
 }
 
-func NewUsersService(dbConn db.Connection) UsersService {
-	return &usersService{usersStore: store.NewUsersStore(dbConn.DB())}
+func NewUsersService(dbConn db.Connection, ledgerService ledgersvc.LedgerService) UsersService {
+	return &usersService{
+		usersStore:    store.NewUsersStore(dbConn.DB()),
+		ledgerService: ledgerService,
+	}
 }
 
 func (s *usersService) CreateUser(ctx context.Context, in *types.CreateUserInput) (*types.UserOutput, error) {
-	_, err := s.usersStore.GetByEmail(ctx, in.Email)
+	currency := in.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	err := money.ValidateCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.usersStore.GetByEmail(ctx, in.Email)
 	if err == mongo.ErrNoDocuments {
 		password, err := passwords.New(in.Password)
 		if err != nil {
@@ -41,7 +69,8 @@ func (s *usersService) CreateUser(ctx context.Context, in *types.CreateUserInput
 			ID:        primitive.NewObjectID(),
 			Email:     in.Email,
 			Password:  password,
-			Balance:   0,
+			Currency:  currency,
+			Balances:  []money.Money{money.Zero(currency)},
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
@@ -93,35 +122,113 @@ func (s *usersService) Credit(ctx context.Context, in *types.CreditInput) (*type
 	if err != nil {
 		return nil, err
 	}
-	if in.Amount <= 0 {
-		return nil, fmt.Errorf("invalid amount to credit: %f", in.Amount)
+	if !in.Amount.IsPositive() || in.Amount.Validate() != nil {
+		return nil, fmt.Errorf("invalid amount to credit: %v", in.Amount)
+	}
+	transactionID, err := s.post(ctx, userID, in.Amount, in.Reason, ledgershared.ReasonTopUp, in.ReferenceID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.usersStore.IncBalance(ctx, userID, in.Amount)
+	if err != nil {
+		s.void(ctx, userID, transactionID)
+		return nil, err
+	}
+	s.checkBalance(ctx, user, in.Amount.Currency)
+	return user.Out(), nil
+}
+
+func (s *usersService) Debit(ctx context.Context, in *types.DebitInput) (*types.UserOutput, error) {
+	userID, err := primitive.ObjectIDFromHex(in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !in.Amount.IsPositive() || in.Amount.Validate() != nil {
+		return nil, fmt.Errorf("invalid amount to debit: %v", in.Amount)
+	}
+	transactionID, err := s.post(ctx, userID, in.Amount.Neg(), in.Reason, ledgershared.ReasonSubscriptionCharge, in.ReferenceID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.usersStore.DecBalance(ctx, userID, in.Amount)
+	if err != nil {
+		s.void(ctx, userID, transactionID)
+	}
+	if err == mongo.ErrNoDocuments {
+		_, err = s.usersStore.Get(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, shared.ErrInsufficientBalance
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.checkBalance(ctx, user, in.Amount.Currency)
+	return user.Out(), nil
+}
+
+func (s *usersService) SetCurrency(ctx context.Context, in *types.SetCurrencyInput) (*types.UserOutput, error) {
+	userID, err := primitive.ObjectIDFromHex(in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	err = money.ValidateCurrency(in.Currency)
+	if err != nil {
+		return nil, err
 	}
 	user, err := s.usersStore.Get(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	user.Balance += in.Amount
+	user.Currency = in.Currency
 	user.UpdatedAt = time.Now()
 	err = s.usersStore.Update(ctx, user)
 	if err != nil {
 		return nil, err
 	}
+	user, err = s.usersStore.IncBalance(ctx, userID, money.Zero(in.Currency))
+	if err != nil {
+		return nil, err
+	}
 	return user.Out(), nil
 }
 
-func (s *usersService) Debit(ctx context.Context, in *types.DebitInput) (*types.UserOutput, error) {
+func (s *usersService) SetBillingAddress(ctx context.Context, in *types.SetBillingAddressInput) (*types.UserOutput, error) {
 	userID, err := primitive.ObjectIDFromHex(in.UserID)
 	if err != nil {
 		return nil, err
 	}
-	if in.Amount <= 0 {
-		return nil, fmt.Errorf("invalid amount to debit: %f", in.Amount)
+	user, err := s.usersStore.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.BillingAddress = &models.Address{
+		Line1:      in.Line1,
+		Line2:      in.Line2,
+		City:       in.City,
+		Region:     in.Region,
+		PostalCode: in.PostalCode,
+		Country:    in.Country,
+	}
+	user.UpdatedAt = time.Now()
+	err = s.usersStore.Update(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return user.Out(), nil
+}
+
+func (s *usersService) SetTaxExempt(ctx context.Context, in *types.SetTaxExemptInput) (*types.UserOutput, error) {
+	userID, err := primitive.ObjectIDFromHex(in.UserID)
+	if err != nil {
+		return nil, err
 	}
 	user, err := s.usersStore.Get(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	user.Balance -= in.Amount
+	user.TaxExempt = in.TaxExempt
 	user.UpdatedAt = time.Now()
 	err = s.usersStore.Update(ctx, user)
 	if err != nil {
@@ -129,3 +236,197 @@ func (s *usersService) Debit(ctx context.Context, in *types.DebitInput) (*types.
 	}
 	return user.Out(), nil
 }
+
+// UseTrial records that the user started the free trial of a plan. It fails
+// with ErrTrialUsed when they already had one on that plan.
+func (s *usersService) UseTrial(ctx context.Context, id, planID string) (*types.UserOutput, error) {
+	userID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	trialPlanID, err := primitive.ObjectIDFromHex(planID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.usersStore.AddTrialPlan(ctx, userID, trialPlanID)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("%w: user_id=%v, plan_id=%v", shared.ErrTrialUsed, id, planID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user.Out(), nil
+}
+
+// ReleaseTrial gives the trial of a plan back when the subscription that used
+// it could not be started.
+func (s *usersService) ReleaseTrial(ctx context.Context, id, planID string) (*types.UserOutput, error) {
+	userID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	trialPlanID, err := primitive.ObjectIDFromHex(planID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.usersStore.RemoveTrialPlan(ctx, userID, trialPlanID)
+	if err != nil {
+		return nil, err
+	}
+	return user.Out(), nil
+}
+
+func (s *usersService) GetPaymentMethods(ctx context.Context, id string) ([]*types.PaymentMethodOutput, error) {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return user.PaymentMethods, nil
+}
+
+func (s *usersService) AddPaymentMethod(ctx context.Context, in *types.AddPaymentMethodInput) (*types.PaymentMethodOutput, error) {
+	if !paymentsshared.GatewayEnabled(in.Gateway) {
+		return nil, fmt.Errorf("%w: %s", paymentsshared.ErrGatewayDisabled, in.Gateway)
+	}
+	userID, err := primitive.ObjectIDFromHex(in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.usersStore.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	method := &models.PaymentMethod{
+		ID:        primitive.NewObjectID(),
+		Gateway:   in.Gateway,
+		Token:     in.Token,
+		Priority:  in.Priority,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	user.PaymentMethods = append(user.PaymentMethods, method)
+	user.SortPaymentMethods()
+	user.UpdatedAt = time.Now()
+	err = s.usersStore.Update(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return method.Out(), nil
+}
+
+func (s *usersService) UpdatePaymentMethod(ctx context.Context, in *types.UpdatePaymentMethodInput) (*types.PaymentMethodOutput, error) {
+	if !paymentsshared.GatewayEnabled(in.Gateway) {
+		return nil, fmt.Errorf("%w: %s", paymentsshared.ErrGatewayDisabled, in.Gateway)
+	}
+	userID, err := primitive.ObjectIDFromHex(in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := primitive.ObjectIDFromHex(in.ID)
+	if err != nil {
+		return nil, shared.ErrPaymentMethodNotFound
+	}
+	user, err := s.usersStore.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	method := user.PaymentMethod(id)
+	if method == nil {
+		return nil, shared.ErrPaymentMethodNotFound
+	}
+	method.Gateway = in.Gateway
+	method.Token = in.Token
+	method.Priority = in.Priority
+	method.UpdatedAt = time.Now()
+	user.SortPaymentMethods()
+	user.UpdatedAt = time.Now()
+	err = s.usersStore.Update(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return method.Out(), nil
+}
+
+func (s *usersService) DeletePaymentMethod(ctx context.Context, in *types.DeletePaymentMethodInput) ([]*types.PaymentMethodOutput, error) {
+	userID, err := primitive.ObjectIDFromHex(in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := primitive.ObjectIDFromHex(in.ID)
+	if err != nil {
+		return nil, shared.ErrPaymentMethodNotFound
+	}
+	user, err := s.usersStore.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	methods := make([]*models.PaymentMethod, 0, len(user.PaymentMethods))
+	for index := range user.PaymentMethods {
+		if user.PaymentMethods[index].ID != id {
+			methods = append(methods, user.PaymentMethods[index])
+		}
+	}
+	if len(methods) == len(user.PaymentMethods) {
+		return nil, shared.ErrPaymentMethodNotFound
+	}
+	user.PaymentMethods = methods
+	user.UpdatedAt = time.Now()
+	err = s.usersStore.Update(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return user.Out().PaymentMethods, nil
+}
+
+func (s *usersService) GetTransactions(ctx context.Context, id string) ([]*types.LedgerEntryOutput, error) {
+	userID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.ledgerService.GetEntries(ctx, ledgershared.UserAccount(userID.Hex()))
+}
+
+func (s *usersService) post(ctx context.Context, userID primitive.ObjectID, amount money.Money, reason, defaultReason, referenceID string) (string, error) {
+	if reason == "" {
+		reason = defaultReason
+	}
+	in := &types.PostTransactionInput{
+		Account:     ledgershared.UserAccount(userID.Hex()),
+		Amount:      amount,
+		Reason:      reason,
+		ReferenceID: referenceID,
+	}
+	entries, err := s.ledgerService.Post(ctx, in)
+	if err != nil {
+		return "", err
+	}
+	return entries[0].TransactionID, nil
+}
+
+// void removes a transaction posted for a balance change that then failed.
+func (s *usersService) void(ctx context.Context, userID primitive.ObjectID, transactionID string) {
+	err := s.ledgerService.Void(ctx, transactionID)
+	if err != nil {
+		log.Printf("void ledger transaction failed: user_id=%v, transaction_id=%v, error=%v\n", userID.Hex(), transactionID, err)
+	}
+}
+
+// checkBalance reports when the cached balance has drifted from the ledger.
+// Concurrent updates can show up as a transient difference, so it only logs.
+func (s *usersService) checkBalance(ctx context.Context, user *models.User, currency string) {
+	balances, err := s.ledgerService.GetBalances(ctx, ledgershared.UserAccount(user.ID.Hex()))
+	if err != nil {
+		log.Printf("ledger balances unavailable: user_id=%v, error=%v\n", user.ID.Hex(), err)
+		return
+	}
+	ledgerBalance := money.Zero(currency)
+	for index := range balances {
+		if balances[index].Currency == currency {
+			ledgerBalance = balances[index]
+		}
+	}
+	if user.Balance(currency).Amount != ledgerBalance.Amount {
+		log.Printf("balance out of sync with ledger: user_id=%v, cached=%v, ledger=%v\n",
+			user.ID.Hex(), user.Balance(currency), ledgerBalance)
+	}
+}
@@ -0,0 +1,11 @@
+package shared
+
+import (
+	"errors"
+)
+
+var (
+	ErrInsufficientBalance   = errors.New("insufficient balance")
+	ErrPaymentMethodNotFound = errors.New("payment method not found")
+	ErrTrialUsed             = errors.New("trial already used")
+)
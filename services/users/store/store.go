@@ -2,16 +2,23 @@ package store
 
 import (
 	"context"
+	"go-subscriptions-workflow/money"
 	"go-subscriptions-workflow/services/users/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"time"
 )
 
 type UsersStore interface {
 	Create(ctx context.Context, user *models.User) error
 	Update(ctx context.Context, user *models.User) error
+	IncBalance(ctx context.Context, id primitive.ObjectID, amount money.Money) (*models.User, error)
+	DecBalance(ctx context.Context, id primitive.ObjectID, amount money.Money) (*models.User, error)
+	AddTrialPlan(ctx context.Context, id, planID primitive.ObjectID) (*models.User, error)
+	RemoveTrialPlan(ctx context.Context, id, planID primitive.ObjectID) (*models.User, error)
 	Get(ctx context.Context, id primitive.ObjectID) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	GetAll(ctx context.Context) ([]*models.User, error)
@@ -39,10 +46,13 @@ func (s *usersStore) Update(ctx context.Context, user *models.User) error {
 
 	update := bson.M{
 		"$set": bson.M{
-			"email":      user.Email,
-			"password":   user.Password,
-			"balance":    user.Balance,
-			"updated_at": user.UpdatedAt,
+			"email":           user.Email,
+			"password":        user.Password,
+			"currency":        user.Currency,
+			"billing_address": user.BillingAddress,
+			"tax_exempt":      user.TaxExempt,
+			"payment_methods": user.PaymentMethods,
+			"updated_at":      user.UpdatedAt,
 		},
 	}
 
@@ -54,6 +64,92 @@ func (s *usersStore) Update(ctx context.Context, user *models.User) error {
 	return nil
 }
 
+// IncBalance adds amount to the balance in its currency, creating that balance
+// when the user does not hold it yet.
+func (s *usersStore) IncBalance(ctx context.Context, id primitive.ObjectID, amount money.Money) (*models.User, error) {
+	user, err := s.incBalance(ctx, id, amount)
+	if err != mongo.ErrNoDocuments {
+		return user, err
+	}
+	filter := bson.M{"_id": id, "balances.currency": bson.M{"$ne": amount.Currency}}
+	update := bson.M{
+		"$push": bson.M{"balances": amount},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	user, err = s.findOneAndUpdate(ctx, filter, update)
+	if err != mongo.ErrNoDocuments {
+		return user, err
+	}
+	return s.incBalance(ctx, id, amount)
+}
+
+// DecBalance subtracts amount only when the balance in its currency covers it;
+// otherwise it returns mongo.ErrNoDocuments and nothing changes.
+func (s *usersStore) DecBalance(ctx context.Context, id primitive.ObjectID, amount money.Money) (*models.User, error) {
+	filter := bson.M{
+		"_id": id,
+		"balances": bson.M{"$elemMatch": bson.M{
+			"currency": amount.Currency,
+			"amount":   bson.M{"$gte": amount.Amount},
+		}},
+	}
+	update := bson.M{
+		"$inc": bson.M{"balances.$.amount": -amount.Amount},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	return s.findOneAndUpdate(ctx, filter, update)
+}
+
+// AddTrialPlan records that the user started a free trial on the plan. It
+// returns mongo.ErrNoDocuments when one is already recorded for that plan.
+func (s *usersStore) AddTrialPlan(ctx context.Context, id, planID primitive.ObjectID) (*models.User, error) {
+	filter := bson.M{"_id": id, "trial_plan_ids": bson.M{"$ne": planID}}
+	update := bson.M{
+		"$push": bson.M{"trial_plan_ids": planID},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	return s.updateTrialPlans(ctx, filter, update)
+}
+
+func (s *usersStore) RemoveTrialPlan(ctx context.Context, id, planID primitive.ObjectID) (*models.User, error) {
+	update := bson.M{
+		"$pull": bson.M{"trial_plan_ids": planID},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	return s.updateTrialPlans(ctx, bson.M{"_id": id}, update)
+}
+
+func (s *usersStore) updateTrialPlans(ctx context.Context, filter, update bson.M) (*models.User, error) {
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("user trials updated: id=%v, trial_plan_ids=%v\n", user.ID.Hex(), user.TrialPlanIDs)
+	return &user, nil
+}
+
+func (s *usersStore) incBalance(ctx context.Context, id primitive.ObjectID, amount money.Money) (*models.User, error) {
+	filter := bson.M{"_id": id, "balances.currency": amount.Currency}
+	update := bson.M{
+		"$inc": bson.M{"balances.$.amount": amount.Amount},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	return s.findOneAndUpdate(ctx, filter, update)
+}
+
+func (s *usersStore) findOneAndUpdate(ctx context.Context, filter, update bson.M) (*models.User, error) {
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("user balance updated: id=%v, balances=%v\n", user.ID.Hex(), user.Balances)
+	return &user, nil
+}
+
 func (s *usersStore) Get(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
 	var user models.User
 	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
@@ -1,19 +1,76 @@
 package types
 
 import (
+	"go-subscriptions-workflow/money"
 	"time"
 )
 
 type CreateUserInput struct {
 	Email    string `json:"email" validate:"email,required"`
 	Password string `json:"password" validate:"required"`
+	Currency string `json:"currency" validate:"omitempty,len=3,uppercase"`
 }
 
 type UserOutput struct {
+	ID             string                 `json:"id"`
+	Email          string                 `json:"email"`
+	Password       string                 `json:"password"`
+	Currency       string                 `json:"currency"`
+	Balances       []money.Money          `json:"balances"`
+	BillingAddress *Address               `json:"billing_address"`
+	TaxExempt      bool                   `json:"tax_exempt"`
+	PaymentMethods []*PaymentMethodOutput `json:"payment_methods"`
+	TrialPlanIDs   []string               `json:"trial_plan_ids,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+type Address struct {
+	Line1      string `json:"line1" validate:"required"`
+	Line2      string `json:"line2"`
+	City       string `json:"city" validate:"required"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country" validate:"required,iso3166_1_alpha2"`
+}
+
+type SetBillingAddressInput struct {
+	UserID string `json:"user_id"`
+	Address
+}
+
+type SetTaxExemptInput struct {
+	UserID    string `json:"user_id"`
+	TaxExempt bool   `json:"tax_exempt"`
+}
+
+type PaymentMethodInput struct {
+	Gateway  string `json:"gateway" validate:"required,oneof=balance fake"`
+	Token    string `json:"token" validate:"required_unless=Gateway balance"`
+	Priority int    `json:"priority" validate:"min=0"`
+}
+
+type AddPaymentMethodInput struct {
+	UserID string `json:"user_id"`
+	PaymentMethodInput
+}
+
+type UpdatePaymentMethodInput struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	PaymentMethodInput
+}
+
+type DeletePaymentMethodInput struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+type PaymentMethodOutput struct {
 	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	Balance   float64   `json:"balance"`
+	Gateway   string    `json:"gateway"`
+	Token     string    `json:"token"`
+	Priority  int       `json:"priority"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -22,14 +79,21 @@ type LoginInput struct {
 	CreateUserInput
 }
 
+type SetCurrencyInput struct {
+	UserID   string `json:"user_id"`
+	Currency string `json:"currency" validate:"required,len=3,uppercase"`
+}
+
 type LoginOutput struct {
 	*UserOutput
 	Token string `json:"token"`
 }
 
 type CreditInput struct {
-	UserID string  `json:"user_id"`
-	Amount float64 `json:"amount" validate:"gte=0"`
+	UserID      string      `json:"user_id"`
+	Amount      money.Money `json:"amount"`
+	Reason      string      `json:"reason"`
+	ReferenceID string      `json:"reference_id"`
 }
 
 type DebitInput struct {
@@ -37,42 +101,557 @@ type DebitInput struct {
 }
 
 type StartSubscriptionRequest struct {
-	UserID string `json:"id"`
+	UserID     string `json:"id"`
+	PlanID     string `json:"plan_id" validate:"required"`
+	Trial      bool   `json:"trial"`
+	CouponCode string `json:"coupon_code"`
 }
 
 type SubscriptionOutput struct {
-	ID          string           `json:"id"`
-	UserID      string           `json:"user_id"`
-	Price       float64          `json:"price"`
-	Features    []*FeatureOutput `json:"features"`
-	Activations int              `json:"activations"`
-	ActivatedAt time.Time        `json:"activated_at"`
-	ExpiresAt   time.Time        `json:"expires_at"`
-	Canceled    bool             `json:"canceled"`
-	CanceledAt  *time.Time       `json:"canceled_at"`
-	Disabled    bool             `json:"disabled"`
-	DisabledAt  *time.Time       `json:"disabled_at"`
-	CreatedAt   time.Time        `json:"created_at"`
-	UpdatedAt   time.Time        `json:"updated_at"`
+	ID                 string                `json:"id"`
+	UserID             string                `json:"user_id"`
+	PlanID             string                `json:"plan_id"`
+	PlanVersion        int                   `json:"plan_version"`
+	PendingPlanID      string                `json:"pending_plan_id,omitempty"`
+	PendingPlanVersion int                   `json:"pending_plan_version,omitempty"`
+	Status             string                `json:"status"`
+	Price              money.Money           `json:"price"`
+	Interval           string                `json:"interval"`
+	IntervalCount      int                   `json:"interval_count"`
+	BillingAnchor      int                   `json:"billing_anchor"`
+	Features           []*FeatureOutput      `json:"features"`
+	MeteredPrices      []*MeteredPriceOutput `json:"metered_prices"`
+	Usage              []*UsageOutput        `json:"usage"`
+	Activations        int                   `json:"activations"`
+	TrialEndsAt        *time.Time            `json:"trial_ends_at"`
+	PastDueSince       *time.Time            `json:"past_due_since"`
+	GraceEndsAt        *time.Time            `json:"grace_ends_at"`
+	PausedAt           *time.Time            `json:"paused_at"`
+	ResumeAt           *time.Time            `json:"resume_at"`
+	Pauses             []*PauseOutput        `json:"pauses"`
+	Discount           *DiscountOutput       `json:"discount"`
+	LastCharge         *ChargeOutput         `json:"last_charge"`
+	ActivatedAt        time.Time             `json:"activated_at"`
+	ExpiresAt          time.Time             `json:"expires_at"`
+	Canceled           bool                  `json:"canceled"`
+	CanceledAt         *time.Time            `json:"canceled_at"`
+	CancelAtPeriodEnd  bool                  `json:"cancel_at_period_end"`
+	AccessEndsAt       *time.Time            `json:"access_ends_at"`
+	Disabled           bool                  `json:"disabled"`
+	DisabledAt         *time.Time            `json:"disabled_at"`
+	CreatedAt          time.Time             `json:"created_at"`
+	UpdatedAt          time.Time             `json:"updated_at"`
 }
 
 type FeatureOutput struct {
 	Name string `json:"name"`
 }
 
+type PauseOutput struct {
+	PausedAt  time.Time  `json:"paused_at"`
+	ResumeAt  *time.Time `json:"resume_at"`
+	ResumedAt *time.Time `json:"resumed_at"`
+}
+
+type DiscountOutput struct {
+	CouponID        string      `json:"coupon_id"`
+	Code            string      `json:"code"`
+	Type            string      `json:"type"`
+	PercentOff      float64     `json:"percent_off"`
+	AmountOff       money.Money `json:"amount_off"`
+	Duration        string      `json:"duration"`
+	CyclesRemaining int         `json:"cycles_remaining"`
+	AppliedAt       time.Time   `json:"applied_at"`
+}
+
+type ChargeOutput struct {
+	Amount          money.Money `json:"amount"`
+	Usage           money.Money `json:"usage"`
+	Discount        money.Money `json:"discount"`
+	Tax             money.Money `json:"tax"`
+	Paid            money.Money `json:"paid"`
+	CouponCode      string      `json:"coupon_code,omitempty"`
+	Gateway         string      `json:"gateway"`
+	PaymentMethodID string      `json:"payment_method_id,omitempty"`
+	PaymentID       string      `json:"payment_id"`
+	ChargedAt       time.Time   `json:"charged_at"`
+}
+
 type ChargeSubscriptionRequest struct {
 	ID string `json:"id"`
 }
 
+type DebitSubscriptionRequest struct {
+	ID      string `json:"id"`
+	Attempt int    `json:"attempt"`
+}
+
+// SubscriptionDebitOutput is a charge paid for the next activation of a
+// subscription that is not recorded on it yet.
+type SubscriptionDebitOutput struct {
+	ID              string              `json:"id"`
+	UserID          string              `json:"user_id"`
+	Activation      int                 `json:"activation"`
+	LedgerReference string              `json:"ledger_reference"`
+	Charge          *ChargeOutput       `json:"charge"`
+	Lines           []*InvoiceLineInput `json:"lines"`
+}
+
+type RecordActivationRequest struct {
+	Debit *SubscriptionDebitOutput `json:"debit"`
+}
+
+type RefundDebitRequest struct {
+	Debit *SubscriptionDebitOutput `json:"debit"`
+}
+
+type RenderReceiptRequest struct {
+	ID         string `json:"id"`
+	Activation int    `json:"activation"`
+}
+
 type CancelSubscriptionRequest struct {
+	ID          string `json:"id"`
+	UserID      string `json:"user_id"`
+	AtPeriodEnd bool   `json:"at_period_end"`
+}
+
+type ReactivateSubscriptionRequest struct {
 	ID     string `json:"id"`
 	UserID string `json:"user_id"`
 }
 
+type SetCancelAtPeriodEndRequest struct {
+	ID                string `json:"id"`
+	CancelAtPeriodEnd bool   `json:"cancel_at_period_end"`
+}
+
+type FinalizeCancelSubscriptionRequest struct {
+	ID string `json:"id"`
+}
+
 type DisableSubscriptionRequest struct {
 	ID string `json:"id"`
 }
 
+type ChangePlanSubscriptionRequest struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	PlanID string `json:"plan_id" validate:"required"`
+}
+
+type ApplyPlanChangeRequest struct {
+	ID          string      `json:"id"`
+	PlanID      string      `json:"plan_id"`
+	PlanVersion int         `json:"plan_version"`
+	Proration   money.Money `json:"proration"`
+	Immediate   bool        `json:"immediate"`
+}
+
+type ApplyCouponSubscriptionRequest struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Code   string `json:"code" validate:"required"`
+}
+
+type RedeemCouponRequest struct {
+	ID   string `json:"id"`
+	Code string `json:"code"`
+}
+
+type MarkPastDueSubscriptionRequest struct {
+	ID           string    `json:"id"`
+	GraceEndsAt  time.Time `json:"grace_ends_at"`
+	PastDueSince time.Time `json:"past_due_since"`
+}
+
+type RetryPaymentRequest struct {
+	UserID string `json:"user_id"`
+}
+
+type PauseSubscriptionRequest struct {
+	ID       string     `json:"id"`
+	UserID   string     `json:"user_id"`
+	ResumeAt *time.Time `json:"resume_at"`
+}
+
+type ResumeSubscriptionRequest struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+type MarkPausedSubscriptionRequest struct {
+	ID       string     `json:"id"`
+	ResumeAt *time.Time `json:"resume_at"`
+	PausedAt time.Time  `json:"paused_at"`
+}
+
+type MarkResumedSubscriptionRequest struct {
+	ID        string    `json:"id"`
+	ResumedAt time.Time `json:"resumed_at"`
+}
+
+type RecordUsageRequest struct {
+	ID       string `json:"id"`
+	UserID   string `json:"user_id"`
+	Feature  string `json:"feature" validate:"required"`
+	Quantity int64  `json:"quantity" validate:"gt=0"`
+}
+
+type GetUsageRequest struct {
+	ID         string `json:"id"`
+	Activation int    `json:"activation"`
+}
+
 type GetSubscriptionRequest struct {
 	ID string `json:"id"`
-}
\ No newline at end of file
+}
+
+type SearchSubscriptionWorkflowsRequest struct {
+	UserID        string     `json:"user_id" validate:"omitempty,hexadecimal"`
+	Status        string     `json:"status" validate:"omitempty,oneof=trialing active past_due paused canceled disabled"`
+	PlanID        string     `json:"plan_id" validate:"omitempty,hexadecimal"`
+	ExpiresAfter  *time.Time `json:"expires_after"`
+	ExpiresBefore *time.Time `json:"expires_before"`
+	Canceled      *bool      `json:"canceled"`
+	Running       bool       `json:"running"`
+	PageSize      int32      `json:"page_size" validate:"min=0,max=1000"`
+	NextPageToken []byte     `json:"next_page_token"`
+}
+
+type SubscriptionWorkflowOutput struct {
+	WorkflowID string     `json:"workflow_id"`
+	RunID      string     `json:"run_id"`
+	Execution  string     `json:"execution"`
+	StartedAt  *time.Time `json:"started_at"`
+	ClosedAt   *time.Time `json:"closed_at"`
+	UserID     string     `json:"user_id"`
+	Status     string     `json:"status"`
+	PlanID     string     `json:"plan_id"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Canceled   bool       `json:"canceled"`
+}
+
+type SubscriptionWorkflowsOutput struct {
+	Workflows     []*SubscriptionWorkflowOutput `json:"workflows"`
+	NextPageToken []byte                        `json:"next_page_token,omitempty"`
+}
+
+type CreatePlanInput struct {
+	Name          string               `json:"name" validate:"required"`
+	Price         money.Money          `json:"price"`
+	Prices        []money.Money        `json:"prices"`
+	Interval      string               `json:"interval" validate:"required,oneof=daily weekly monthly yearly"`
+	IntervalCount int                  `json:"interval_count" validate:"gte=0"`
+	TrialPeriod   string               `json:"trial_period"`
+	Features      []string             `json:"features"`
+	MeteredPrices []*MeteredPriceInput `json:"metered_prices" validate:"dive"`
+}
+
+type MeteredPriceInput struct {
+	Feature   string       `json:"feature" validate:"required"`
+	Pricing   string       `json:"pricing" validate:"required,oneof=per_unit tiered"`
+	UnitPrice money.Money  `json:"unit_price"`
+	Tiers     []*TierInput `json:"tiers" validate:"dive"`
+}
+
+type TierInput struct {
+	UpTo      int64       `json:"up_to" validate:"gte=0"`
+	UnitPrice money.Money `json:"unit_price"`
+	FlatPrice money.Money `json:"flat_price"`
+}
+
+type UpdatePlanInput struct {
+	ID string `json:"id"`
+	CreatePlanInput
+}
+
+type PlanOutput struct {
+	ID            string                `json:"id"`
+	Name          string                `json:"name"`
+	Price         money.Money           `json:"price"`
+	Prices        []money.Money         `json:"prices"`
+	Interval      string                `json:"interval"`
+	IntervalCount int                   `json:"interval_count"`
+	TrialPeriod   string                `json:"trial_period"`
+	Features      []*FeatureOutput      `json:"features"`
+	MeteredPrices []*MeteredPriceOutput `json:"metered_prices"`
+	Version       int                   `json:"version"`
+	Archived      bool                  `json:"archived"`
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+}
+
+type MeteredPriceOutput struct {
+	Feature   string        `json:"feature"`
+	Pricing   string        `json:"pricing"`
+	UnitPrice money.Money   `json:"unit_price"`
+	Tiers     []*TierOutput `json:"tiers"`
+}
+
+type TierOutput struct {
+	UpTo      int64       `json:"up_to"`
+	UnitPrice money.Money `json:"unit_price"`
+	FlatPrice money.Money `json:"flat_price"`
+}
+
+type CreateCouponInput struct {
+	Code           string      `json:"code" validate:"required"`
+	Type           string      `json:"type" validate:"required,oneof=percent fixed"`
+	PercentOff     float64     `json:"percent_off" validate:"gte=0,lte=100"`
+	AmountOff      money.Money `json:"amount_off"`
+	Duration       string      `json:"duration" validate:"required,oneof=once repeating forever"`
+	DurationCycles int         `json:"duration_cycles" validate:"gte=0"`
+	MaxRedemptions int         `json:"max_redemptions" validate:"gte=0"`
+	ExpiresAt      *time.Time  `json:"expires_at"`
+}
+
+// CouponRedemptionInput redeems a coupon for a subscription, or releases that
+// redemption. Each subscription counts once against the coupon's redemptions.
+type CouponRedemptionInput struct {
+	Code           string `json:"code"`
+	SubscriptionID string `json:"subscription_id"`
+}
+
+type CouponOutput struct {
+	ID             string      `json:"id"`
+	Code           string      `json:"code"`
+	Type           string      `json:"type"`
+	PercentOff     float64     `json:"percent_off"`
+	AmountOff      money.Money `json:"amount_off"`
+	Duration       string      `json:"duration"`
+	DurationCycles int         `json:"duration_cycles"`
+	MaxRedemptions int         `json:"max_redemptions"`
+	Redemptions    int         `json:"redemptions"`
+	ExpiresAt      *time.Time  `json:"expires_at"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+type EntitlementsOutput struct {
+	UserID    string    `json:"user_id"`
+	Features  []string  `json:"features"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type RecordUsageInput struct {
+	SubscriptionID string `json:"subscription_id"`
+	UserID         string `json:"user_id"`
+	Feature        string `json:"feature"`
+	Quantity       int64  `json:"quantity"`
+	Activation     int    `json:"activation"`
+}
+
+type UsageRecordOutput struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscription_id"`
+	UserID         string    `json:"user_id"`
+	Feature        string    `json:"feature"`
+	Quantity       int64     `json:"quantity"`
+	Activation     int       `json:"activation"`
+	RecordedAt     time.Time `json:"recorded_at"`
+}
+
+type UsageOutput struct {
+	Feature  string      `json:"feature"`
+	Quantity int64       `json:"quantity"`
+	Amount   money.Money `json:"amount"`
+}
+
+type SetExchangeRateInput struct {
+	Base  string  `json:"base" validate:"required,len=3,uppercase"`
+	Quote string  `json:"quote" validate:"required,len=3,uppercase,nefield=Base"`
+	Rate  float64 `json:"rate" validate:"gt=0"`
+}
+
+type ExchangeRateOutput struct {
+	ID        string    `json:"id"`
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Rate      float64   `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PostTransactionInput struct {
+	Account     string      `json:"account"`
+	Amount      money.Money `json:"amount"`
+	Reason      string      `json:"reason"`
+	ReferenceID string      `json:"reference_id"`
+}
+
+type LedgerEntryOutput struct {
+	ID            string      `json:"id"`
+	TransactionID string      `json:"transaction_id"`
+	Account       string      `json:"account"`
+	Amount        money.Money `json:"amount"`
+	Reason        string      `json:"reason"`
+	ReferenceID   string      `json:"reference_id"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+type InvoiceLineInput struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Quantity    int64       `json:"quantity"`
+	Amount      money.Money `json:"amount"`
+	Inclusive   bool        `json:"inclusive"`
+}
+
+type CreateInvoiceInput struct {
+	UserID          string              `json:"user_id"`
+	SubscriptionID  string              `json:"subscription_id"`
+	Status          string              `json:"status"`
+	Lines           []*InvoiceLineInput `json:"lines"`
+	Paid            money.Money         `json:"paid"`
+	PeriodStart     time.Time           `json:"period_start"`
+	PeriodEnd       time.Time           `json:"period_end"`
+	LedgerReference string              `json:"ledger_reference"`
+	Gateway         string              `json:"gateway"`
+	PaymentID       string              `json:"payment_id"`
+}
+
+type GetInvoiceInput struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+type InvoiceLineOutput struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Quantity    int64       `json:"quantity"`
+	Amount      money.Money `json:"amount"`
+	Inclusive   bool        `json:"inclusive"`
+}
+
+type InvoiceOutput struct {
+	ID              string               `json:"id"`
+	Number          int64                `json:"number"`
+	UserID          string               `json:"user_id"`
+	SubscriptionID  string               `json:"subscription_id"`
+	Status          string               `json:"status"`
+	Lines           []*InvoiceLineOutput `json:"lines"`
+	Subtotal        money.Money          `json:"subtotal"`
+	Discount        money.Money          `json:"discount"`
+	Tax             money.Money          `json:"tax"`
+	Total           money.Money          `json:"total"`
+	Paid            money.Money          `json:"paid"`
+	Refunded        money.Money          `json:"refunded"`
+	PeriodStart     time.Time            `json:"period_start"`
+	PeriodEnd       time.Time            `json:"period_end"`
+	LedgerReference string               `json:"ledger_reference"`
+	Gateway         string               `json:"gateway"`
+	PaymentID       string               `json:"payment_id"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+}
+
+type CreateReceiptInput struct {
+	InvoiceID string      `json:"invoice_id"`
+	Email     string      `json:"email"`
+	Plan      string      `json:"plan"`
+	Balance   money.Money `json:"balance"`
+}
+
+type ReceiptOutput struct {
+	ID        string    `json:"id"`
+	InvoiceID string    `json:"invoice_id"`
+	UserID    string    `json:"user_id"`
+	Number    int64     `json:"number"`
+	HTML      []byte    `json:"html"`
+	PDF       []byte    `json:"pdf"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateCreditNoteInput struct {
+	InvoiceID string      `json:"invoice_id"`
+	RefundID  string      `json:"refund_id"`
+	Amount    money.Money `json:"amount"`
+	Refunded  money.Money `json:"refunded"`
+	Reason    string      `json:"reason"`
+}
+
+type CreditNoteOutput struct {
+	ID              string      `json:"id"`
+	Number          int64       `json:"number"`
+	RefundID        string      `json:"refund_id"`
+	Status          string      `json:"status"`
+	InvoiceID       string      `json:"invoice_id"`
+	UserID          string      `json:"user_id"`
+	SubscriptionID  string      `json:"subscription_id"`
+	Amount          money.Money `json:"amount"`
+	Refunded        money.Money `json:"refunded"`
+	Reason          string      `json:"reason"`
+	LedgerReference string      `json:"ledger_reference"`
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+type RefundRequest struct {
+	InvoiceID string       `json:"invoice_id"`
+	RefundID  string       `json:"refund_id"`
+	Amount    *money.Money `json:"amount"`
+	Reason    string       `json:"reason"`
+	Cancel    bool         `json:"cancel"`
+}
+
+type SetTaxRateInput struct {
+	Country   string  `json:"country" validate:"required,iso3166_1_alpha2"`
+	Region    string  `json:"region"`
+	Name      string  `json:"name" validate:"required"`
+	Percent   float64 `json:"percent" validate:"gt=0,lte=100"`
+	Inclusive bool    `json:"inclusive"`
+}
+
+type TaxRateOutput struct {
+	ID        string    `json:"id"`
+	Country   string    `json:"country"`
+	Region    string    `json:"region"`
+	Name      string    `json:"name"`
+	Percent   float64   `json:"percent"`
+	Inclusive bool      `json:"inclusive"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CalculateTaxInput struct {
+	Country   string      `json:"country"`
+	Region    string      `json:"region"`
+	TaxExempt bool        `json:"tax_exempt"`
+	Amount    money.Money `json:"amount"`
+}
+
+type TaxOutput struct {
+	Name      string      `json:"name"`
+	Percent   float64     `json:"percent"`
+	Inclusive bool        `json:"inclusive"`
+	Net       money.Money `json:"net"`
+	Tax       money.Money `json:"tax"`
+	Gross     money.Money `json:"gross"`
+}
+
+type AuthorizePaymentInput struct {
+	IdempotencyKey string      `json:"idempotency_key"`
+	UserID         string      `json:"user_id"`
+	Source         string      `json:"source"`
+	Amount         money.Money `json:"amount"`
+}
+
+type CapturePaymentInput struct {
+	IdempotencyKey string      `json:"idempotency_key"`
+	PaymentID      string      `json:"payment_id"`
+	UserID         string      `json:"user_id"`
+	Amount         money.Money `json:"amount"`
+}
+
+type RefundPaymentInput struct {
+	IdempotencyKey string      `json:"idempotency_key"`
+	PaymentID      string      `json:"payment_id"`
+	UserID         string      `json:"user_id"`
+	Amount         money.Money `json:"amount"`
+}
+
+type PaymentOutput struct {
+	ID        string      `json:"id"`
+	Gateway   string      `json:"gateway"`
+	Status    string      `json:"status"`
+	Amount    money.Money `json:"amount"`
+	CreatedAt time.Time   `json:"created_at"`
+}